@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type CloseAccountRequestBody struct {
+	Pwd string `json:"password"`
+}
+
+type CloseAccountResponseBody struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+}
+
+// Pesanan dianggap masih berjalan selama belum punya status selesai atau batal.
+const ordersInProgressQuery = `SELECT COUNT(*) FROM TR_PEMESANAN_JASA tpj
+	WHERE (tpj.IdPelanggan = $1 OR tpj.IdPekerja = $1)
+	AND NOT EXISTS (
+		SELECT 1 FROM TR_PEMESANAN_STATUS tps
+		JOIN STATUS_PESANAN sp ON sp.Id = tps.IdStatus
+		WHERE tps.IdTrPemesanan = tpj.Id AND sp.Status IN ('Pesanan selesai', 'Pesanan dibatal')
+	)`
+
+// checkAccountPassword memastikan permintaan penutupan akun datang dari
+// pemilik akun, bukan sekadar dari access token yang dicuri.
+func checkAccountPassword(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return "", false
+	}
+
+	var body CloseAccountRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+
+	userID := currentUserID(r)
+
+	var storedPwd string
+	err = db.QueryRow(`SELECT Pwd FROM "user" WHERE Id = $1`, userID).Scan(&storedPwd)
+	if err != nil {
+		json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return "", false
+	}
+
+	if ok, _ := verifyPassword(storedPwd, body.Pwd); !ok {
+		json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+			Status:  false,
+			Message: "Password salah",
+		})
+		return "", false
+	}
+
+	return userID, true
+}
+
+// deactivateAccount memblokir login dan menyembunyikan profil pekerja tanpa
+// menghapus data apa pun. Seperti eraseAccount, akun yang masih punya pesanan
+// berjalan tidak bisa dinonaktifkan agar pesanan itu tidak terbengkalai.
+func deactivateAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := checkAccountPassword(w, r)
+	if !ok {
+		return
+	}
+
+	var ordersInProgress int
+	err := db.QueryRow(ordersInProgressQuery, userID).Scan(&ordersInProgress)
+	if err != nil {
+		json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if ordersInProgress > 0 {
+		json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+			Status:  false,
+			Message: "Masih ada pesanan yang sedang berjalan",
+		})
+		return
+	}
+
+	_, err = db.Exec(`UPDATE "user" SET DeactivatedAt = NOW() WHERE Id = $1 AND DeactivatedAt IS NULL`, userID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := revokeAllSessions(db, userID); err != nil {
+		log.Printf("Error revoking sessions for %s: %v", userID, err)
+	}
+	catalogResponses.invalidate()
+
+	json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+		Status:  true,
+		Message: "Akun berhasil dinonaktifkan",
+	})
+}
+
+// eraseAccount menganonimkan data pribadi user. Baris TR_MYPAY dan
+// TR_PEMESANAN_JASA tetap disimpan untuk pembukuan.
+func eraseAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := checkAccountPassword(w, r)
+	if !ok {
+		return
+	}
+
+	var saldo float64
+	var noHP string
+	err := db.QueryRow(`SELECT SaldoMyPay, NoHP FROM "user" WHERE Id = $1`, userID).Scan(&saldo, &noHP)
+	if err != nil {
+		json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if saldo != 0 {
+		json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+			Status:  false,
+			Message: "Saldo MyPay harus kosong sebelum akun dihapus",
+		})
+		return
+	}
+
+	var ordersInProgress int
+	err = db.QueryRow(ordersInProgressQuery, userID).Scan(&ordersInProgress)
+	if err != nil {
+		json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if ordersInProgress > 0 {
+		json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+			Status:  false,
+			Message: "Masih ada pesanan yang sedang berjalan",
+		})
+		return
+	}
+
+	if err := anonymiseUser(db, userID, noHP); err != nil {
+		json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	catalogResponses.invalidate()
+
+	json.NewEncoder(w).Encode(&CloseAccountResponseBody{
+		Status:  true,
+		Message: "Data pribadi berhasil dihapus",
+	})
+}
+
+func anonymiseUser(db *sql.DB, userID, noHP string) error {
+	// Password diganti hash dari nilai acak yang tidak pernah disimpan
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	pwdHash, err := hashPassword(hex.EncodeToString(secret))
+	if err != nil {
+		return err
+	}
+
+	// NoHP harus tetap unik, jadi diganti penanda yang diturunkan dari Id
+	erasedNoHP := "DEL" + strings.ReplaceAll(userID, "-", "")[:12]
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE "user" SET
+	Nama = 'Pengguna Terhapus',
+	NoHP = $1,
+	NoHPBaru = NULL,
+	Alamat = '',
+	Pwd = $2,
+	DeactivatedAt = COALESCE(DeactivatedAt, NOW()),
+	ErasedAt = NOW()
+	WHERE Id = $3`, erasedNoHP, pwdHash, userID)
+	if err != nil {
+		return err
+	}
+
+	var linkFoto, linkThumbnail string
+	err = tx.QueryRow(`SELECT COALESCE(LinkFoto, ''), COALESCE(LinkFotoThumbnail, '') FROM PEKERJA WHERE Id = $1`, userID).
+		Scan(&linkFoto, &linkThumbnail)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE PEKERJA SET NPWP = '', NamaBank = '', NomorRekening = '', LinkFoto = '', LinkFotoThumbnail = NULL WHERE Id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := resetRekeningVerification(tx, userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM ALAMAT_USER WHERE UserId = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := revokeAllSessions(tx, userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM PHONE_OTP WHERE NoHP = $1`, noHP)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM LOGIN_ATTEMPT WHERE AttemptKey = $1`, phoneLoginPolicy.prefix+noHP)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// File foto baru dihapus setelah datanya tidak lagi ditunjuk
+	deletePekerjaPhotos(userID, linkFoto, linkThumbnail)
+	return nil
+}
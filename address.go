@@ -0,0 +1,449 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var kodePosPattern = regexp.MustCompile(`^[0-9]{5}$`)
+
+var errAlamatTidakDitemukan = errors.New("alamat tidak ditemukan")
+
+type AlamatItem struct {
+	Id        string  `json:"id"`
+	Label     string  `json:"label"`
+	Jalan     string  `json:"street"`
+	Kota      string  `json:"city"`
+	KodePos   string  `json:"postalCode"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	IsDefault bool    `json:"isDefault"`
+}
+
+// AlamatRequestBody dipakai untuk menambah maupun mengubah alamat. Koordinat
+// berupa pointer agar nilai 0 bisa dibedakan dari field yang tidak dikirim.
+type AlamatRequestBody struct {
+	Id        string   `json:"id"`
+	Label     string   `json:"label"`
+	Jalan     string   `json:"street"`
+	Kota      string   `json:"city"`
+	KodePos   string   `json:"postalCode"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	IsDefault bool     `json:"isDefault"`
+}
+
+type AlamatIdRequestBody struct {
+	Id string `json:"id"`
+}
+
+type AlamatResponseBody struct {
+	Status  bool         `json:"status"`
+	Message string       `json:"message"`
+	Errors  FieldErrors  `json:"errors,omitempty"`
+	Alamat  []AlamatItem `json:"alamat"`
+}
+
+// alamatPesananJoin menggabungkan TR_PEMESANAN_JASA AS TJ dengan alamat yang
+// dipilih pelanggan sebagai AU.
+const alamatPesananJoin = `LEFT JOIN ALAMAT_USER AS AU ON AU.Id = TJ.IdAlamat`
+
+// alamatPesananColumns memilih teks alamat dan koordinat pesanan, dengan
+// "user" AS U sebagai pelanggan. Koordinat yang sudah disalin ke pesanan
+// diutamakan, dan "user".Alamat dipakai jika pelanggan belum punya alamat.
+const alamatPesananColumns = `COALESCE(AU.Jalan || ', ' || AU.Kota || ' ' || AU.KodePos, U.Alamat, ''),
+	COALESCE(TJ.Latitude, AU.Latitude), COALESCE(TJ.Longitude, AU.Longitude)`
+
+const radiusBumiKm = 6371.0
+
+// jarakKm menghitung jarak lingkaran besar (haversine) antara dua koordinat.
+func jarakKm(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * radiusBumiKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// formatAlamat menyusun teks alamat lama dari alamat terstruktur supaya
+// "user".Alamat tetap terisi untuk client versi lama.
+func formatAlamat(jalan, kota, kodePos string) string {
+	return strings.TrimSpace(jalan) + ", " + strings.TrimSpace(kota) + " " + strings.TrimSpace(kodePos)
+}
+
+func validateAlamatRequest(body AlamatRequestBody) FieldErrors {
+	errs := FieldErrors{}
+	validateRequired(errs, "label", body.Label, "Label alamat wajib diisi")
+	validateRequired(errs, "street", body.Jalan, "Jalan wajib diisi")
+	validateRequired(errs, "city", body.Kota, "Kota wajib diisi")
+	if !kodePosPattern.MatchString(body.KodePos) {
+		errs.add("postalCode", "Kode pos harus 5 digit angka")
+	}
+	if body.Latitude == nil || *body.Latitude < -90 || *body.Latitude > 90 {
+		errs.add("latitude", "Latitude wajib diisi dengan nilai -90 sampai 90")
+	}
+	if body.Longitude == nil || *body.Longitude < -180 || *body.Longitude > 180 {
+		errs.add("longitude", "Longitude wajib diisi dengan nilai -180 sampai 180")
+	}
+	return errs
+}
+
+func listAlamat(q sqlQuerier, userID string) ([]AlamatItem, error) {
+	rows, err := q.Query(`SELECT Id, Label, Jalan, Kota, KodePos, Latitude, Longitude, IsDefault
+	FROM ALAMAT_USER WHERE UserId = $1
+	ORDER BY IsDefault DESC, CreatedAt`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alamat := []AlamatItem{}
+	for rows.Next() {
+		var item AlamatItem
+		err := rows.Scan(&item.Id, &item.Label, &item.Jalan, &item.Kota, &item.KodePos, &item.Latitude, &item.Longitude, &item.IsDefault)
+		if err != nil {
+			return nil, err
+		}
+		alamat = append(alamat, item)
+	}
+	return alamat, rows.Err()
+}
+
+// loadDefaultAlamat mengembalikan sql.ErrNoRows jika user belum punya alamat.
+func loadDefaultAlamat(q sqlQuerier, userID string) (*AlamatItem, error) {
+	item := &AlamatItem{}
+	err := q.QueryRow(`SELECT Id, Label, Jalan, Kota, KodePos, Latitude, Longitude, IsDefault
+	FROM ALAMAT_USER WHERE UserId = $1 AND IsDefault`, userID).Scan(
+		&item.Id, &item.Label, &item.Jalan, &item.Kota, &item.KodePos, &item.Latitude, &item.Longitude, &item.IsDefault)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// setDefaultAlamat menjadikan alamatID satu-satunya alamat default user dan
+// menyalinnya ke "user".Alamat.
+func setDefaultAlamat(q sqlQuerier, userID, alamatID string) error {
+	_, err := q.Exec(`UPDATE ALAMAT_USER SET IsDefault = FALSE WHERE UserId = $1 AND IsDefault AND Id <> $2`, userID, alamatID)
+	if err != nil {
+		return err
+	}
+
+	var jalan, kota, kodePos string
+	err = q.QueryRow(`UPDATE ALAMAT_USER SET IsDefault = TRUE WHERE Id = $1 AND UserId = $2
+	RETURNING Jalan, Kota, KodePos`, alamatID, userID).Scan(&jalan, &kota, &kodePos)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(`UPDATE "user" SET Alamat = $1 WHERE Id = $2`, formatAlamat(jalan, kota, kodePos), userID)
+	return err
+}
+
+// chooseAlamatPesanan mengembalikan alamat yang dipilih pelanggan untuk
+// pesanan, atau alamat default jika alamatID kosong. Hasil nil tanpa error
+// berarti pelanggan belum punya alamat, sedangkan alamatID yang bukan milik
+// userID menghasilkan errAlamatTidakDitemukan.
+func chooseAlamatPesanan(q sqlQuerier, userID, alamatID string) (*AlamatItem, error) {
+	if alamatID == "" {
+		alamat, err := loadDefaultAlamat(q, userID)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return alamat, err
+	}
+
+	item := &AlamatItem{}
+	err := q.QueryRow(`SELECT Id, Label, Jalan, Kota, KodePos, Latitude, Longitude, IsDefault
+	FROM ALAMAT_USER WHERE Id::text = $1 AND UserId = $2`, alamatID, userID).Scan(
+		&item.Id, &item.Label, &item.Jalan, &item.Kota, &item.KodePos, &item.Latitude, &item.Longitude, &item.IsDefault)
+	if err == sql.ErrNoRows {
+		return nil, errAlamatTidakDitemukan
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func insertAlamat(db *sql.DB, userID string, body AlamatRequestBody) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	alamatID := uuid.New().String()
+	_, err = tx.Exec(`INSERT INTO ALAMAT_USER (Id, UserId, Label, Jalan, Kota, KodePos, Latitude, Longitude, IsDefault, CreatedAt)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, FALSE, NOW())`,
+		alamatID, userID, body.Label, body.Jalan, body.Kota, body.KodePos, *body.Latitude, *body.Longitude)
+	if err != nil {
+		return err
+	}
+
+	// Alamat pertama otomatis menjadi default
+	_, err = loadDefaultAlamat(tx, userID)
+	if body.IsDefault || err == sql.ErrNoRows {
+		err = setDefaultAlamat(tx, userID, alamatID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func updateAlamatRow(db *sql.DB, userID string, body AlamatRequestBody) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var isDefault bool
+	err = tx.QueryRow(`UPDATE ALAMAT_USER SET Label = $1, Jalan = $2, Kota = $3, KodePos = $4, Latitude = $5, Longitude = $6
+	WHERE Id = $7 AND UserId = $8 RETURNING IsDefault`,
+		body.Label, body.Jalan, body.Kota, body.KodePos, *body.Latitude, *body.Longitude, body.Id, userID).Scan(&isDefault)
+	if err != nil {
+		return err
+	}
+
+	// Alamat default yang diubah tetap disalin ke "user".Alamat
+	if body.IsDefault || isDefault {
+		if err := setDefaultAlamat(tx, userID, body.Id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func changeDefaultAlamat(db *sql.DB, userID, alamatID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setDefaultAlamat(tx, userID, alamatID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteAlamat(db *sql.DB, userID, alamatID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var wasDefault bool
+	err = tx.QueryRow(`DELETE FROM ALAMAT_USER WHERE Id = $1 AND UserId = $2 RETURNING IsDefault`,
+		alamatID, userID).Scan(&wasDefault)
+	if err != nil {
+		return err
+	}
+
+	// Alamat terbaru yang tersisa menggantikan alamat default yang dihapus
+	if wasDefault {
+		var nextID string
+		err = tx.QueryRow(`SELECT Id FROM ALAMAT_USER WHERE UserId = $1 ORDER BY CreatedAt DESC LIMIT 1`, userID).Scan(&nextID)
+		if err == nil {
+			err = setDefaultAlamat(tx, userID, nextID)
+		} else if err == sql.ErrNoRows {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func writeAlamat(w http.ResponseWriter, userID, message string) {
+	alamat, err := listAlamat(db, userID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&AlamatResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(&AlamatResponseBody{
+		Status:  true,
+		Message: message,
+		Alamat:  alamat,
+	})
+}
+
+func decodeAlamatRequest(w http.ResponseWriter, r *http.Request) (AlamatRequestBody, bool) {
+	var body AlamatRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return body, false
+	}
+
+	if errs := validateAlamatRequest(body); len(errs) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(&AlamatResponseBody{
+			Status:  false,
+			Message: "Data alamat tidak valid",
+			Errors:  errs,
+		})
+		return body, false
+	}
+	return body, true
+}
+
+func decodeAlamatIdRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var body AlamatIdRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+
+	if _, err := uuid.Parse(body.Id); err != nil {
+		http.Error(w, "Invalid id format", http.StatusBadRequest)
+		return "", false
+	}
+	return body.Id, true
+}
+
+func getAlamat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeAlamat(w, currentUserID(r), "Berhasil mendapatkan data")
+}
+
+func addAlamat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, ok := decodeAlamatRequest(w, r)
+	if !ok {
+		return
+	}
+
+	userID := currentUserID(r)
+	if err := insertAlamat(db, userID, body); err != nil {
+		json.NewEncoder(w).Encode(&AlamatResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	writeAlamat(w, userID, "Alamat berhasil ditambahkan")
+}
+
+func updateAlamat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, ok := decodeAlamatRequest(w, r)
+	if !ok {
+		return
+	}
+	if _, err := uuid.Parse(body.Id); err != nil {
+		http.Error(w, "Invalid id format", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUserID(r)
+	err := updateAlamatRow(db, userID, body)
+	if err == sql.ErrNoRows {
+		json.NewEncoder(w).Encode(&AlamatResponseBody{
+			Status:  false,
+			Message: "Alamat tidak ditemukan",
+		})
+		return
+	} else if err != nil {
+		json.NewEncoder(w).Encode(&AlamatResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	writeAlamat(w, userID, "Alamat berhasil diubah")
+}
+
+func setDefaultAlamatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	alamatID, ok := decodeAlamatIdRequest(w, r)
+	if !ok {
+		return
+	}
+
+	userID := currentUserID(r)
+	err := changeDefaultAlamat(db, userID, alamatID)
+	if err == sql.ErrNoRows {
+		json.NewEncoder(w).Encode(&AlamatResponseBody{
+			Status:  false,
+			Message: "Alamat tidak ditemukan",
+		})
+		return
+	} else if err != nil {
+		json.NewEncoder(w).Encode(&AlamatResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	writeAlamat(w, userID, "Alamat default berhasil diubah")
+}
+
+func removeAlamat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	alamatID, ok := decodeAlamatIdRequest(w, r)
+	if !ok {
+		return
+	}
+
+	userID := currentUserID(r)
+	err := deleteAlamat(db, userID, alamatID)
+	if err == sql.ErrNoRows {
+		json.NewEncoder(w).Encode(&AlamatResponseBody{
+			Status:  false,
+			Message: "Alamat tidak ditemukan",
+		})
+		return
+	} else if err != nil {
+		json.NewEncoder(w).Encode(&AlamatResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	writeAlamat(w, userID, "Alamat berhasil dihapus")
+}
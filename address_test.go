@@ -0,0 +1,25 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestJarakKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"titik yang sama", -6.2, 106.8, -6.2, 106.8, 0},
+		{"Monas ke Bundaran HI", -6.1754, 106.8272, -6.1950, 106.8230, 2.2},
+		{"Jakarta ke Bandung", -6.2088, 106.8456, -6.9175, 107.6191, 116},
+		{"satu derajat di khatulistiwa", 0, 0, 0, 1, 111.2},
+	}
+	for _, tt := range tests {
+		got := jarakKm(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+		if math.Abs(got-tt.want) > tt.want*0.02+0.01 {
+			t.Errorf("%s: jarakKm = %.2f, want sekitar %.2f", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// catalogTable menjelaskan satu tabel katalog untuk operasi yang sama di
+// semua level: arsip dan pengurutan. Item diarsipkan, tidak pernah dihapus,
+// supaya pesanan lama tetap menunjuk ke baris yang ada.
+type catalogTable struct {
+	Table          string
+	IdColumn       string
+	ArchivedColumn string
+	OrderColumn    string
+	ParentColumn   string
+	Label          string
+}
+
+var (
+	kategoriCatalog = catalogTable{
+		Table:          "KATEGORI_JASA",
+		IdColumn:       "Id",
+		ArchivedColumn: "ArchivedAt",
+		OrderColumn:    "Urutan",
+		Label:          "Kategori",
+	}
+	subkategoriCatalog = catalogTable{
+		Table:          "SUBKATEGORI_JASA",
+		IdColumn:       "Id",
+		ArchivedColumn: "ArchivedAt",
+		OrderColumn:    "Urutan",
+		ParentColumn:   "KategoriJasaId",
+		Label:          "Subkategori",
+	}
+	sesiCatalog = catalogTable{
+		Table:          "sesi_layanan",
+		IdColumn:       "id",
+		ArchivedColumn: "archived_at",
+		OrderColumn:    "urutan",
+		ParentColumn:   "id_subkategori",
+		Label:          "Sesi",
+	}
+)
+
+type CatalogAdminResponseBody struct {
+	Status  bool        `json:"status"`
+	Message string      `json:"message"`
+	Errors  FieldErrors `json:"errors,omitempty"`
+	Id      string      `json:"id,omitempty"`
+}
+
+type KategoriAdminRequestBody struct {
+	Id   string `json:"id"`
+	Nama string `json:"nama"`
+}
+
+type SubkategoriAdminRequestBody struct {
+	Id         string `json:"id"`
+	KategoriId string `json:"kategoriId"`
+	Nama       string `json:"nama"`
+	Deskripsi  string `json:"deskripsi"`
+}
+
+type SesiAdminRequestBody struct {
+	Id            string  `json:"id"`
+	SubkategoriId string  `json:"subkategoriId"`
+	Nama          string  `json:"nama"`
+	Harga         float64 `json:"harga"`
+}
+
+type ArchiveCatalogRequestBody struct {
+	Id       string `json:"id"`
+	Archived bool   `json:"archived"`
+}
+
+type ReorderCatalogRequestBody struct {
+	ParentId string   `json:"parentId"`
+	Ids      []string `json:"ids"`
+}
+
+func writeCatalogInvalid(w http.ResponseWriter, errs FieldErrors) {
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(&CatalogAdminResponseBody{
+		Status:  false,
+		Message: "Data katalog tidak valid",
+		Errors:  errs,
+	})
+}
+
+func writeCatalogResult(w http.ResponseWriter, id, message string, err error) {
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(&CatalogAdminResponseBody{
+			Status:  false,
+			Message: "Data katalog tidak ditemukan",
+		})
+		return
+	} else if err != nil {
+		json.NewEncoder(w).Encode(&CatalogAdminResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Katalog berubah, response /homepage dan /subkategori harus dibuat ulang
+	catalogResponses.invalidate()
+
+	json.NewEncoder(w).Encode(&CatalogAdminResponseBody{
+		Status:  true,
+		Message: message,
+		Id:      id,
+	})
+}
+
+// validateCatalogParent memastikan induk ada dan belum diarsipkan, karena
+// item baru di bawah induk yang diarsipkan tidak akan pernah terlihat.
+func validateCatalogParent(errs FieldErrors, field string, parent catalogTable, parentID string) {
+	if _, err := uuid.Parse(parentID); err != nil {
+		errs.add(field, "Id tidak valid")
+		return
+	}
+
+	var archived bool
+	err := db.QueryRow(fmt.Sprintf(`SELECT %s IS NOT NULL FROM %s WHERE %s = $1`,
+		parent.ArchivedColumn, parent.Table, parent.IdColumn), parentID).Scan(&archived)
+	if err == sql.ErrNoRows {
+		errs.add(field, parent.Label+" tidak ditemukan")
+	} else if err != nil {
+		errs.add(field, err.Error())
+	} else if archived {
+		errs.add(field, parent.Label+" sudah diarsipkan")
+	}
+}
+
+func validateSesiHarga(errs FieldErrors, harga float64) {
+	if harga <= 0 {
+		errs.add("harga", "Harga sesi harus lebih dari 0")
+	}
+}
+
+// nextCatalogOrder menaruh item baru di urutan paling akhir dalam induknya.
+func nextCatalogOrder(t catalogTable, parentID string) (int, error) {
+	var next int
+	var err error
+	if t.ParentColumn == "" {
+		err = db.QueryRow(fmt.Sprintf(`SELECT COALESCE(MAX(%s), 0) + 1 FROM %s`, t.OrderColumn, t.Table)).Scan(&next)
+	} else {
+		err = db.QueryRow(fmt.Sprintf(`SELECT COALESCE(MAX(%s), 0) + 1 FROM %s WHERE %s = $1`,
+			t.OrderColumn, t.Table, t.ParentColumn), parentID).Scan(&next)
+	}
+	return next, err
+}
+
+func createKategori(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body KategoriAdminRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := FieldErrors{}
+	validateRequired(errs, "nama", body.Nama, "Nama kategori wajib diisi")
+	if len(errs) > 0 {
+		writeCatalogInvalid(w, errs)
+		return
+	}
+
+	urutan, err := nextCatalogOrder(kategoriCatalog, "")
+	if err != nil {
+		writeCatalogResult(w, "", "", err)
+		return
+	}
+
+	id := uuid.New().String()
+	_, err = db.Exec(`INSERT INTO KATEGORI_JASA (Id, NamaKategori, Urutan) VALUES ($1, $2, $3)`, id, body.Nama, urutan)
+	writeCatalogResult(w, id, "Kategori berhasil dibuat", err)
+}
+
+func updateKategori(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body KategoriAdminRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(body.Id); err != nil {
+		http.Error(w, "Invalid id format", http.StatusBadRequest)
+		return
+	}
+
+	errs := FieldErrors{}
+	validateRequired(errs, "nama", body.Nama, "Nama kategori wajib diisi")
+	if len(errs) > 0 {
+		writeCatalogInvalid(w, errs)
+		return
+	}
+
+	var id string
+	err := db.QueryRow(`UPDATE KATEGORI_JASA SET NamaKategori = $1 WHERE Id = $2 RETURNING Id`, body.Nama, body.Id).Scan(&id)
+	writeCatalogResult(w, id, "Kategori berhasil diubah", err)
+}
+
+func createSubkategori(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body SubkategoriAdminRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := FieldErrors{}
+	validateRequired(errs, "nama", body.Nama, "Nama subkategori wajib diisi")
+	validateCatalogParent(errs, "kategoriId", kategoriCatalog, body.KategoriId)
+	if len(errs) > 0 {
+		writeCatalogInvalid(w, errs)
+		return
+	}
+
+	urutan, err := nextCatalogOrder(subkategoriCatalog, body.KategoriId)
+	if err != nil {
+		writeCatalogResult(w, "", "", err)
+		return
+	}
+
+	id := uuid.New().String()
+	_, err = db.Exec(`INSERT INTO SUBKATEGORI_JASA (Id, NamaSubkategori, Deskripsi, KategoriJasaId, Urutan)
+	VALUES ($1, $2, $3, $4, $5)`, id, body.Nama, body.Deskripsi, body.KategoriId, urutan)
+	writeCatalogResult(w, id, "Subkategori berhasil dibuat", err)
+}
+
+func updateSubkategori(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body SubkategoriAdminRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(body.Id); err != nil {
+		http.Error(w, "Invalid id format", http.StatusBadRequest)
+		return
+	}
+
+	errs := FieldErrors{}
+	validateRequired(errs, "nama", body.Nama, "Nama subkategori wajib diisi")
+	validateCatalogParent(errs, "kategoriId", kategoriCatalog, body.KategoriId)
+	if len(errs) > 0 {
+		writeCatalogInvalid(w, errs)
+		return
+	}
+
+	var id string
+	err := db.QueryRow(`UPDATE SUBKATEGORI_JASA SET NamaSubkategori = $1, Deskripsi = $2, KategoriJasaId = $3
+	WHERE Id = $4 RETURNING Id`, body.Nama, body.Deskripsi, body.KategoriId, body.Id).Scan(&id)
+	writeCatalogResult(w, id, "Subkategori berhasil diubah", err)
+}
+
+func createSesi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body SesiAdminRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := FieldErrors{}
+	validateRequired(errs, "nama", body.Nama, "Nama sesi wajib diisi")
+	validateSesiHarga(errs, body.Harga)
+	validateCatalogParent(errs, "subkategoriId", subkategoriCatalog, body.SubkategoriId)
+	if len(errs) > 0 {
+		writeCatalogInvalid(w, errs)
+		return
+	}
+
+	urutan, err := nextCatalogOrder(sesiCatalog, body.SubkategoriId)
+	if err != nil {
+		writeCatalogResult(w, "", "", err)
+		return
+	}
+
+	var id string
+	err = db.QueryRow(`INSERT INTO sesi_layanan (id_subkategori, nama_sesi, harga, urutan)
+	VALUES ($1, $2, $3, $4) RETURNING id`, body.SubkategoriId, body.Nama, body.Harga, urutan).Scan(&id)
+	writeCatalogResult(w, id, "Sesi berhasil dibuat", err)
+}
+
+func updateSesi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body SesiAdminRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := FieldErrors{}
+	validateRequired(errs, "nama", body.Nama, "Nama sesi wajib diisi")
+	validateSesiHarga(errs, body.Harga)
+	if len(errs) > 0 {
+		writeCatalogInvalid(w, errs)
+		return
+	}
+
+	var id string
+	err := db.QueryRow(`UPDATE sesi_layanan SET nama_sesi = $1, harga = $2
+	WHERE id::text = $3 RETURNING id`, body.Nama, body.Harga, body.Id).Scan(&id)
+	writeCatalogResult(w, id, "Sesi berhasil diubah", err)
+}
+
+// archiveCatalogHandler mengarsipkan atau memulihkan item katalog. Item yang
+// diarsipkan tidak lagi muncul di daftar untuk pelanggan.
+func archiveCatalogHandler(t catalogTable) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body ArchiveCatalogRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		var id string
+		err := db.QueryRow(fmt.Sprintf(`UPDATE %s SET %s = CASE WHEN $1 THEN COALESCE(%s, NOW()) END
+		WHERE %s::text = $2 RETURNING %s::text`,
+			t.Table, t.ArchivedColumn, t.ArchivedColumn, t.IdColumn, t.IdColumn), body.Archived, body.Id).Scan(&id)
+
+		message := t.Label + " berhasil dipulihkan"
+		if body.Archived {
+			message = t.Label + " berhasil diarsipkan"
+		}
+		writeCatalogResult(w, id, message, err)
+	}
+}
+
+// reorderCatalogHandler mengisi urutan sesuai posisi id di body. Untuk
+// subkategori dan sesi, semua id harus berada di bawah parentId yang sama.
+func reorderCatalogHandler(t catalogTable) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body ReorderCatalogRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		errs := FieldErrors{}
+		seen := map[string]bool{}
+		for _, id := range body.Ids {
+			if seen[id] {
+				errs.add("ids", "Id tidak boleh duplikat")
+			}
+			seen[id] = true
+		}
+		if len(body.Ids) == 0 {
+			errs.add("ids", "Daftar id wajib diisi")
+		}
+		if len(errs) > 0 {
+			writeCatalogInvalid(w, errs)
+			return
+		}
+
+		err := reorderCatalog(db, t, body.ParentId, body.Ids)
+		if err == sql.ErrNoRows {
+			writeCatalogInvalid(w, FieldErrors{"ids": "Semua id harus ada dan berada di induk yang sama"})
+			return
+		}
+		writeCatalogResult(w, "", "Urutan "+t.Label+" berhasil disimpan", err)
+	}
+}
+
+func reorderCatalog(db *sql.DB, t catalogTable, parentID string, ids []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var matched int
+	if t.ParentColumn == "" {
+		err = tx.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s::text = ANY($1)`,
+			t.Table, t.IdColumn), pq.Array(ids)).Scan(&matched)
+	} else {
+		err = tx.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s::text = ANY($1) AND %s::text = $2`,
+			t.Table, t.IdColumn, t.ParentColumn), pq.Array(ids), parentID).Scan(&matched)
+	}
+	if err != nil {
+		return err
+	}
+	if matched != len(ids) {
+		return sql.ErrNoRows
+	}
+
+	for i, id := range ids {
+		_, err = tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = $1 WHERE %s::text = $2`,
+			t.Table, t.OrderColumn, t.IdColumn), i+1, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
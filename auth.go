@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	accessTokenTTL    = 15 * time.Minute
+	defaultAuthSecret = "sijarta-dev-secret"
+)
+
+type contextKey string
+
+const (
+	userIDContextKey    contextKey = "userId"
+	sessionIDContextKey contextKey = "sessionId"
+)
+
+var (
+	errInvalidToken = errors.New("token tidak valid")
+	errExpiredToken = errors.New("token sudah kedaluwarsa")
+	errRevokedToken = errors.New("sesi sudah berakhir")
+)
+
+type AccessTokenClaims struct {
+	Subject   string `json:"sub"`
+	SessionId string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var errAuthSecretMissing = errors.New("AUTH_SECRET belum diisi, set AUTH_DEV_MODE=1 untuk memakai secret development")
+
+// authSecret diisi oleh main sebelum server menerima request.
+var authSecret []byte
+
+// loadAuthSecret membaca kunci penandatangan token. defaultAuthSecret ada di
+// repo sehingga siapa pun bisa memalsukan token dengannya, jadi kunci itu hanya
+// dipakai jika AUTH_DEV_MODE=1 diset secara eksplisit.
+func loadAuthSecret() ([]byte, error) {
+	if secret := os.Getenv("AUTH_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+	if os.Getenv("AUTH_DEV_MODE") != "1" {
+		return nil, errAuthSecretMissing
+	}
+	log.Println("AUTH_SECRET is not set, using the development secret because AUTH_DEV_MODE=1")
+	return []byte(defaultAuthSecret), nil
+}
+
+func signToken(claims AccessTokenClaims) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + tokenSignature(unsigned), nil
+}
+
+func tokenSignature(unsigned string) string {
+	mac := hmac.New(sha256.New, authSecret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issueAccessToken membuat access token HS256 untuk sesi milik userID yang
+// berlaku selama accessTokenTTL.
+func issueAccessToken(userID, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(accessTokenTTL)
+
+	token, err := signToken(AccessTokenClaims{
+		Subject:   userID,
+		SessionId: sessionID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	return token, expiresAt, err
+}
+
+func parseAccessToken(token string) (*AccessTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errInvalidToken
+	}
+
+	expected := tokenSignature(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	var claims AccessTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" || claims.SessionId == "" {
+		return nil, errInvalidToken
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, errExpiredToken
+	}
+
+	return &claims, nil
+}
+
+// authMiddleware menolak request tanpa access token yang valid di header
+// Authorization dan meneruskan identitas pemanggil lewat context request.
+func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if header == "" || token == header {
+			http.Error(w, "Missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := parseAccessToken(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		active, err := isSessionActive(db, claims.SessionId)
+		if err != nil {
+			http.Error(w, "Failed to check session", http.StatusInternalServerError)
+			return
+		} else if !active {
+			http.Error(w, errRevokedToken.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		role, err := resolveRole(db, claims.Subject)
+		if err != nil {
+			http.Error(w, "Failed to resolve role", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDContextKey, claims.Subject)
+		ctx = context.WithValue(ctx, sessionIDContextKey, claims.SessionId)
+		ctx = context.WithValue(ctx, roleContextKey, role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// optionalAuthMiddleware meneruskan request tanpa header Authorization
+// sebagai tamu, sedangkan request yang membawa token tetap diperiksa seperti
+// authMiddleware.
+func optionalAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	withAuth := authMiddleware(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next(w, r)
+			return
+		}
+		withAuth(w, r)
+	}
+}
+
+// currentUserID mengembalikan id user yang sudah diverifikasi oleh
+// authMiddleware.
+func currentUserID(r *http.Request) string {
+	userID, _ := r.Context().Value(userIDContextKey).(string)
+	return userID
+}
+
+func currentSessionID(r *http.Request) string {
+	sessionID, _ := r.Context().Value(sessionIDContextKey).(string)
+	return sessionID
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JamKerjaItem adalah satu hari kerja mingguan pekerja. Pesanan dikerjakan per
+// hari, jadi yang dicatat hanya harinya, bukan jam mulai dan selesainya.
+type JamKerjaItem struct {
+	HariKe int `json:"hari"`
+}
+
+type CutiItem struct {
+	Id         string `json:"id"`
+	TglMulai   string `json:"tglMulai"`
+	TglSelesai string `json:"tglSelesai"`
+	Keterangan string `json:"keterangan"`
+}
+
+type AvailabilityResponseBody struct {
+	Status   bool           `json:"status"`
+	Message  string         `json:"message"`
+	Errors   FieldErrors    `json:"errors,omitempty"`
+	JamKerja []JamKerjaItem `json:"jamKerja"`
+	Cuti     []CutiItem     `json:"cuti"`
+}
+
+type SetJamKerjaRequestBody struct {
+	JamKerja []JamKerjaItem `json:"jamKerja"`
+}
+
+type AddCutiRequestBody struct {
+	TglMulai   string `json:"tglMulai"`
+	TglSelesai string `json:"tglSelesai"`
+	Keterangan string `json:"keterangan"`
+}
+
+type RemoveCutiRequestBody struct {
+	Id string `json:"id"`
+}
+
+type busyInterval struct {
+	Mulai   time.Time
+	Selesai time.Time
+	Alasan  string
+}
+
+// workerCalendar berisi hari kerja mingguan serta rentang waktu ketika pekerja
+// sudah terisi, baik oleh pekerjaan lain maupun cuti.
+type workerCalendar struct {
+	JamKerja []JamKerjaItem
+	Busy     []busyInterval
+}
+
+// jobSchedule menentukan rentang hari pengerjaan pesanan, yaitu sesi hari
+// mulai dari tanggal pesanan, atau mulai hari ini jika tanggal itu sudah
+// lewat. Hasilnya tanggal jam 00:00 dalam bentuk wallClock.
+func jobSchedule(tglPemesanan, now time.Time, sesi int) (time.Time, time.Time) {
+	start := dateOnly(tglPemesanan)
+	if today := dateOnly(wallClock(now)); start.Before(today) {
+		start = today
+	}
+	if sesi < 1 {
+		sesi = 1
+	}
+	return start, start.AddDate(0, 0, sesi)
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// wallClock menyamakan waktu dengan cara lib/pq membaca kolom TIMESTAMP tanpa
+// zona waktu, yaitu jam dinding apa adanya dalam UTC.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func loadJamKerja(q sqlQuerier, pekerjaID string) ([]JamKerjaItem, error) {
+	rows, err := q.Query(`SELECT HariKe
+	FROM PEKERJA_JAM_KERJA WHERE PekerjaId = $1 ORDER BY HariKe`, pekerjaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jamKerja := []JamKerjaItem{}
+	for rows.Next() {
+		var item JamKerjaItem
+		if err := rows.Scan(&item.HariKe); err != nil {
+			return nil, err
+		}
+		jamKerja = append(jamKerja, item)
+	}
+	return jamKerja, rows.Err()
+}
+
+// loadCuti mengembalikan cuti yang belum berakhir pada tanggal from.
+func loadCuti(q sqlQuerier, pekerjaID string, from time.Time) ([]CutiItem, error) {
+	rows, err := q.Query(`SELECT Id, to_char(TglMulai, 'YYYY-MM-DD'), to_char(TglSelesai, 'YYYY-MM-DD'), Keterangan
+	FROM PEKERJA_CUTI WHERE PekerjaId = $1 AND TglSelesai >= $2
+	ORDER BY TglMulai`, pekerjaID, from.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cuti := []CutiItem{}
+	for rows.Next() {
+		var item CutiItem
+		if err := rows.Scan(&item.Id, &item.TglMulai, &item.TglSelesai, &item.Keterangan); err != nil {
+			return nil, err
+		}
+		cuti = append(cuti, item)
+	}
+	return cuti, rows.Err()
+}
+
+// loadWorkerCalendar membaca jadwal pekerja mulai dari now. Pesanan
+// excludeOrderID tidak dihitung sebagai bentrok, misalnya saat pesanan yang
+// sama diambil ulang.
+func loadWorkerCalendar(q sqlQuerier, pekerjaID, excludeOrderID string, now time.Time) (*workerCalendar, error) {
+	jamKerja, err := loadJamKerja(q, pekerjaID)
+	if err != nil {
+		return nil, err
+	}
+	calendar := &workerCalendar{JamKerja: jamKerja}
+
+	cuti, err := loadCuti(q, pekerjaID, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range cuti {
+		mulai, err := time.Parse("2006-01-02", item.TglMulai)
+		if err != nil {
+			return nil, err
+		}
+		selesai, err := time.Parse("2006-01-02", item.TglSelesai)
+		if err != nil {
+			return nil, err
+		}
+		calendar.Busy = append(calendar.Busy, busyInterval{
+			Mulai:   mulai,
+			Selesai: selesai.AddDate(0, 0, 1),
+			Alasan:  "Jadwal bentrok dengan cuti " + item.TglMulai + " s.d. " + item.TglSelesai,
+		})
+	}
+
+	rows, err := q.Query(`SELECT tpj.TglPekerjaan::timestamp, tpj.WaktuPekerjaan
+	FROM TR_PEMESANAN_JASA tpj
+	WHERE tpj.IdPekerja = $1
+	AND tpj.Id::text <> $2
+	AND tpj.TglPekerjaan IS NOT NULL
+	AND tpj.WaktuPekerjaan > $3
+	AND NOT EXISTS (
+		SELECT 1 FROM TR_PEMESANAN_STATUS tps
+		JOIN STATUS_PESANAN sp ON sp.Id = tps.IdStatus
+		WHERE tps.IdTrPemesanan = tpj.Id AND sp.Status IN ('Pesanan selesai', 'Pesanan dibatal')
+	)`, pekerjaID, excludeOrderID, wallClock(now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		interval := busyInterval{Alasan: "Jadwal bentrok dengan pekerjaan lain"}
+		if err := rows.Scan(&interval.Mulai, &interval.Selesai); err != nil {
+			return nil, err
+		}
+		calendar.Busy = append(calendar.Busy, interval)
+	}
+	return calendar, rows.Err()
+}
+
+// worksOn memeriksa apakah hari tersebut merupakan hari kerja pekerja. Pekerja
+// yang belum mengisi hari kerja dianggap bekerja setiap hari.
+func (c *workerCalendar) worksOn(day time.Weekday) bool {
+	if len(c.JamKerja) == 0 {
+		return true
+	}
+	for _, item := range c.JamKerja {
+		if item.HariKe == int(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// conflict mengembalikan alasan jika pekerjaan pada rentang hari start-end
+// (hasil jobSchedule) tidak muat di jadwal, atau string kosong jika muat.
+// Pekerjaan dihitung per hari, jadi setiap hari dalam rentang harus merupakan
+// hari kerja dan tidak bentrok dengan cuti maupun pekerjaan lain.
+func (c *workerCalendar) conflict(start, end time.Time) string {
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !c.worksOn(day.Weekday()) {
+			return "Pekerja tidak bekerja pada " + day.Format("2006-01-02")
+		}
+	}
+
+	for _, busy := range c.Busy {
+		if start.Before(busy.Selesai) && busy.Mulai.Before(end) {
+			return busy.Alasan
+		}
+	}
+	return ""
+}
+
+func writeAvailability(w http.ResponseWriter, pekerjaID, message string) {
+	jamKerja, err := loadJamKerja(db, pekerjaID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&AvailabilityResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	cuti, err := loadCuti(db, pekerjaID, time.Now())
+	if err != nil {
+		json.NewEncoder(w).Encode(&AvailabilityResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(&AvailabilityResponseBody{
+		Status:   true,
+		Message:  message,
+		JamKerja: jamKerja,
+		Cuti:     cuti,
+	})
+}
+
+func getPekerjaAvailability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeAvailability(w, currentUserID(r), "Berhasil mendapatkan data")
+}
+
+func validateJamKerja(jamKerja []JamKerjaItem) FieldErrors {
+	errs := FieldErrors{}
+	seen := map[int]bool{}
+	for i, item := range jamKerja {
+		field := fmt.Sprintf("jamKerja.%d", i)
+		if item.HariKe < 0 || item.HariKe > 6 {
+			errs.add(field, "Hari harus bernilai 0 (Minggu) sampai 6 (Sabtu)")
+			continue
+		}
+		if seen[item.HariKe] {
+			errs.add(field, "Hari tidak boleh diisi lebih dari sekali")
+			continue
+		}
+		seen[item.HariKe] = true
+	}
+	return errs
+}
+
+// setPekerjaJamKerja mengganti seluruh hari kerja mingguan pekerja. Daftar
+// kosong berarti pekerja tersedia setiap hari.
+func setPekerjaJamKerja(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body SetJamKerjaRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if errs := validateJamKerja(body.JamKerja); len(errs) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(&AvailabilityResponseBody{
+			Status:  false,
+			Message: "Hari kerja tidak valid",
+			Errors:  errs,
+		})
+		return
+	}
+
+	pekerjaID := currentUserID(r)
+	err = replaceJamKerja(db, pekerjaID, body.JamKerja)
+	if err != nil {
+		json.NewEncoder(w).Encode(&AvailabilityResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	writeAvailability(w, pekerjaID, "Hari kerja berhasil disimpan")
+}
+
+func replaceJamKerja(db *sql.DB, pekerjaID string, jamKerja []JamKerjaItem) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM PEKERJA_JAM_KERJA WHERE PekerjaId = $1`, pekerjaID)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range jamKerja {
+		_, err = tx.Exec(`INSERT INTO PEKERJA_JAM_KERJA (PekerjaId, HariKe)
+		VALUES ($1, $2)`, pekerjaID, item.HariKe)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func addPekerjaCuti(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body AddCutiRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := FieldErrors{}
+	mulai, err := time.Parse("2006-01-02", body.TglMulai)
+	if err != nil {
+		errs.add("tglMulai", "Tanggal harus berformat YYYY-MM-DD")
+	}
+	selesai, err := time.Parse("2006-01-02", body.TglSelesai)
+	if err != nil {
+		errs.add("tglSelesai", "Tanggal harus berformat YYYY-MM-DD")
+	} else if selesai.Before(mulai) {
+		errs.add("tglSelesai", "Tanggal selesai tidak boleh sebelum tanggal mulai")
+	} else if body.TglSelesai < time.Now().Format("2006-01-02") {
+		errs.add("tglSelesai", "Tanggal selesai sudah lewat")
+	}
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(&AvailabilityResponseBody{
+			Status:  false,
+			Message: "Data cuti tidak valid",
+			Errors:  errs,
+		})
+		return
+	}
+
+	pekerjaID := currentUserID(r)
+	_, err = db.Exec(`INSERT INTO PEKERJA_CUTI (Id, PekerjaId, TglMulai, TglSelesai, Keterangan)
+	VALUES ($1, $2, $3, $4, $5)`, uuid.New(), pekerjaID, body.TglMulai, body.TglSelesai, body.Keterangan)
+	if err != nil {
+		json.NewEncoder(w).Encode(&AvailabilityResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	writeAvailability(w, pekerjaID, "Cuti berhasil ditambahkan")
+}
+
+func removePekerjaCuti(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body RemoveCutiRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := uuid.Parse(body.Id); err != nil {
+		http.Error(w, "Invalid id format", http.StatusBadRequest)
+		return
+	}
+
+	pekerjaID := currentUserID(r)
+	result, err := db.Exec(`DELETE FROM PEKERJA_CUTI WHERE Id = $1 AND PekerjaId = $2`, body.Id, pekerjaID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&AvailabilityResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		json.NewEncoder(w).Encode(&AvailabilityResponseBody{
+			Status:  false,
+			Message: "Cuti tidak ditemukan",
+		})
+		return
+	}
+
+	writeAvailability(w, pekerjaID, "Cuti berhasil dihapus")
+}
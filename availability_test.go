@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func tanggal(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestJobSchedule(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	// Selasa 2024-05-14 pukul 23:30 WIB
+	now := time.Date(2024, 5, 14, 23, 30, 0, 0, jakarta)
+
+	tests := []struct {
+		name         string
+		tglPemesanan string
+		sesi         int
+		wantStart    string
+		wantEnd      string
+	}{
+		{"tanggal di masa depan", "2024-05-20", 2, "2024-05-20", "2024-05-22"},
+		{"tanggal hari ini", "2024-05-14", 1, "2024-05-14", "2024-05-15"},
+		{"tanggal sudah lewat dimulai hari ini", "2024-05-10", 3, "2024-05-14", "2024-05-17"},
+		{"sesi kosong dihitung satu hari", "2024-05-20", 0, "2024-05-20", "2024-05-21"},
+	}
+	for _, tt := range tests {
+		start, end := jobSchedule(tanggal(tt.tglPemesanan), now, tt.sesi)
+		if !start.Equal(tanggal(tt.wantStart)) || !end.Equal(tanggal(tt.wantEnd)) {
+			t.Errorf("%s: jobSchedule = %s - %s, want %s - %s", tt.name,
+				start.Format("2006-01-02"), end.Format("2006-01-02"), tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+func TestWorkerCalendarConflict(t *testing.T) {
+	// Senin sampai Jumat, 2024-05-13 adalah hari Senin
+	weekdays := []JamKerjaItem{{HariKe: 1}, {HariKe: 2}, {HariKe: 3}, {HariKe: 4}, {HariKe: 5}}
+	cuti := busyInterval{Mulai: tanggal("2024-05-22"), Selesai: tanggal("2024-05-24"), Alasan: "cuti"}
+	pekerjaan := busyInterval{
+		Mulai:   tanggal("2024-05-15"),
+		Selesai: time.Date(2024, 5, 16, 10, 0, 0, 0, time.UTC),
+		Alasan:  "pekerjaan lain",
+	}
+
+	tests := []struct {
+		name     string
+		calendar workerCalendar
+		start    string
+		sesi     int
+		conflict bool
+	}{
+		{"tanpa jam kerja selalu tersedia", workerCalendar{}, "2024-05-18", 2, false},
+		{"hari kerja", workerCalendar{JamKerja: weekdays}, "2024-05-13", 1, false},
+		{"seluruh hari kerja dalam seminggu", workerCalendar{JamKerja: weekdays}, "2024-05-13", 5, false},
+		{"mulai di hari libur", workerCalendar{JamKerja: weekdays}, "2024-05-18", 1, true},
+		{"beberapa hari melewati akhir pekan", workerCalendar{JamKerja: weekdays}, "2024-05-16", 3, true},
+		{"bentrok dengan cuti", workerCalendar{JamKerja: weekdays, Busy: []busyInterval{cuti}}, "2024-05-21", 2, true},
+		{"selesai tepat sebelum cuti", workerCalendar{JamKerja: weekdays, Busy: []busyInterval{cuti}}, "2024-05-20", 2, false},
+		{"bentrok dengan pekerjaan lain", workerCalendar{Busy: []busyInterval{pekerjaan}}, "2024-05-16", 1, true},
+		{"setelah pekerjaan lain selesai", workerCalendar{Busy: []busyInterval{pekerjaan}}, "2024-05-17", 1, false},
+	}
+	for _, tt := range tests {
+		start := tanggal(tt.start)
+		reason := tt.calendar.conflict(start, start.AddDate(0, 0, tt.sesi))
+		if (reason != "") != tt.conflict {
+			t.Errorf("%s: conflict = %q, want conflict %v", tt.name, reason, tt.conflict)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	rekeningUnverified = "unverified"
+	rekeningVerified   = "verified"
+	rekeningRejected   = "rejected"
+)
+
+var errRekeningNotVerified = errors.New("rekening bank belum terverifikasi")
+
+// BankAccount adalah rekening yang diperiksa beserta nama pemilik yang
+// diharapkan, yaitu "user".Nama.
+type BankAccount struct {
+	NamaBank      string
+	NomorRekening string
+	NamaPemilik   string
+}
+
+type BankVerificationResult struct {
+	Verified bool
+	Reason   string
+}
+
+// BankVerifier memeriksa apakah rekening ada dan atas nama pemilik yang
+// diharapkan. Rekening yang ditolak dilaporkan lewat Reason, sedangkan error
+// hanya untuk kegagalan teknis sehingga status rekening tidak berubah.
+type BankVerifier interface {
+	Verify(account BankAccount) (BankVerificationResult, error)
+}
+
+// fakeBankVerifier dipakai untuk development lokal dan hasilnya bergantung
+// pada digit terakhir nomor rekening: 0 berarti rekening tidak ditemukan, 1
+// berarti rekening atas nama orang lain, selain itu rekening valid.
+type fakeBankVerifier struct{}
+
+func (fakeBankVerifier) Verify(account BankAccount) (BankVerificationResult, error) {
+	nomor := account.NomorRekening
+	if nomor == "" {
+		return BankVerificationResult{Reason: "Rekening tidak ditemukan"}, nil
+	}
+
+	var pemilik string
+	switch nomor[len(nomor)-1] {
+	case '0':
+		return BankVerificationResult{Reason: "Rekening tidak ditemukan"}, nil
+	case '1':
+		pemilik = "PEMILIK LAIN"
+	default:
+		pemilik = strings.ToUpper(account.NamaPemilik)
+	}
+
+	if !sameHolderName(pemilik, account.NamaPemilik) {
+		return BankVerificationResult{Reason: "Nama pemilik rekening tidak sesuai dengan nama akun"}, nil
+	}
+	return BankVerificationResult{Verified: true}, nil
+}
+
+// sameHolderName membandingkan nama tanpa memperhatikan huruf besar dan
+// spasi berlebih, karena bank biasanya mengembalikan nama dalam huruf kapital.
+func sameHolderName(a, b string) bool {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToUpper(s)), " ")
+	}
+	return normalize(a) != "" && normalize(a) == normalize(b)
+}
+
+// newBankVerifier memilih implementasi berdasarkan BANK_VERIFIER. Saat ini
+// hanya "fake" yang tersedia.
+func newBankVerifier() BankVerifier {
+	switch name := os.Getenv("BANK_VERIFIER"); name {
+	case "", "fake":
+		return fakeBankVerifier{}
+	default:
+		log.Printf("Unknown BANK_VERIFIER %q, using fake verifier", name)
+		return fakeBankVerifier{}
+	}
+}
+
+var bankVerifier = newBankVerifier()
+
+type RekeningResponseBody struct {
+	Status             bool       `json:"status"`
+	Message            string     `json:"message"`
+	NamaBank           string     `json:"bank,omitempty"`
+	NomorRekening      string     `json:"noRek,omitempty"`
+	RekeningStatus     string     `json:"rekeningStatus,omitempty"`
+	RekeningCatatan    string     `json:"rekeningCatatan,omitempty"`
+	RekeningVerifiedAt *time.Time `json:"rekeningVerifiedAt,omitempty"`
+}
+
+type WithdrawRequestBody struct {
+	UserID     string  `json:"user_id"`
+	KategoriID string  `json:"kategori_id"`
+	Nominal    float64 `json:"nominal"`
+}
+
+// kategoriPencairan memeriksa apakah kategori TR_MYPAY adalah pencairan saldo
+// ke rekening bank. Kategori yang tidak ada menghasilkan sql.ErrNoRows.
+func kategoriPencairan(q sqlQuerier, kategoriID string) (bool, error) {
+	var pencairan bool
+	err := q.QueryRow(`SELECT IsPencairan FROM KATEGORI_TR_MYPAY WHERE Id = $1`, kategoriID).Scan(&pencairan)
+	return pencairan, err
+}
+
+// checkMyPayCredit memastikan top-up dan transfer hanya menambah saldo,
+// sehingga setiap pencairan harus lewat handleWithdraw yang memeriksa status
+// rekening. Bernilai false jika respons kesalahan sudah ditulis.
+func checkMyPayCredit(w http.ResponseWriter, nominal float64, kategoriID string) bool {
+	if nominal <= 0 {
+		http.Error(w, "Nominal harus lebih dari 0", http.StatusBadRequest)
+		return false
+	}
+	if _, err := uuid.Parse(kategoriID); err != nil {
+		http.Error(w, "Invalid kategori_id format", http.StatusBadRequest)
+		return false
+	}
+
+	pencairan, err := kategoriPencairan(db, kategoriID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Kategori tidak ditemukan", http.StatusBadRequest)
+		return false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if pencairan {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Penarikan saldo hanya bisa melalui /mypay/withdraw"})
+		return false
+	}
+	return true
+}
+
+// resetRekeningVerification dipanggil setiap kali rekening atau nama pemilik
+// berubah sehingga pekerja harus memverifikasi ulang.
+func resetRekeningVerification(q sqlQuerier, pekerjaID string) error {
+	_, err := q.Exec(`UPDATE PEKERJA SET RekeningStatus = $1, RekeningCatatan = NULL, RekeningVerifiedAt = NULL
+	WHERE Id = $2`, rekeningUnverified, pekerjaID)
+	return err
+}
+
+func writeRekening(w http.ResponseWriter, pekerjaID, message string) {
+	response := &RekeningResponseBody{}
+	var catatan sql.NullString
+	var verifiedAt sql.NullTime
+	err := db.QueryRow(`SELECT NamaBank, NomorRekening, RekeningStatus, RekeningCatatan, RekeningVerifiedAt
+	FROM PEKERJA WHERE Id = $1`, pekerjaID).Scan(
+		&response.NamaBank,
+		&response.NomorRekening,
+		&response.RekeningStatus,
+		&catatan,
+		&verifiedAt)
+	if err != nil {
+		json.NewEncoder(w).Encode(&RekeningResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response.Status = true
+	response.Message = message
+	response.RekeningCatatan = catatan.String
+	if verifiedAt.Valid {
+		response.RekeningVerifiedAt = &verifiedAt.Time
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func getPekerjaRekening(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeRekening(w, currentUserID(r), "Berhasil mendapatkan data")
+}
+
+func verifyPekerjaRekening(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pekerjaID := currentUserID(r)
+
+	var account BankAccount
+	var status string
+	err := db.QueryRow(`SELECT p.NamaBank, p.NomorRekening, u.Nama, p.RekeningStatus
+	FROM PEKERJA p
+	JOIN "user" u ON u.Id = p.Id
+	WHERE p.Id = $1`, pekerjaID).Scan(&account.NamaBank, &account.NomorRekening, &account.NamaPemilik, &status)
+	if err != nil {
+		json.NewEncoder(w).Encode(&RekeningResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if status == rekeningVerified {
+		writeRekening(w, pekerjaID, "Rekening sudah terverifikasi")
+		return
+	}
+
+	result, err := bankVerifier.Verify(account)
+	if err != nil {
+		json.NewEncoder(w).Encode(&RekeningResponseBody{
+			Status:  false,
+			Message: "Verifikasi rekening gagal: " + err.Error(),
+		})
+		return
+	}
+
+	status = rekeningRejected
+	if result.Verified {
+		status = rekeningVerified
+	}
+
+	// Hasil hanya disimpan jika rekening dan nama belum berubah sejak diperiksa
+	updated, err := db.Exec(`UPDATE PEKERJA p SET
+	RekeningStatus = $1,
+	RekeningCatatan = NULLIF($2, ''),
+	RekeningVerifiedAt = CASE WHEN $3 THEN NOW() END
+	FROM "user" u
+	WHERE p.Id = $4 AND u.Id = p.Id
+	AND p.NamaBank = $5 AND p.NomorRekening = $6 AND u.Nama = $7`,
+		status, result.Reason, result.Verified, pekerjaID, account.NamaBank, account.NomorRekening, account.NamaPemilik)
+	if err != nil {
+		json.NewEncoder(w).Encode(&RekeningResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+	if affected, _ := updated.RowsAffected(); affected == 0 {
+		json.NewEncoder(w).Encode(&RekeningResponseBody{
+			Status:  false,
+			Message: "Data rekening berubah saat diverifikasi, silakan ulangi",
+		})
+		return
+	}
+
+	if !result.Verified {
+		writeRekening(w, pekerjaID, "Rekening tidak dapat diverifikasi")
+		return
+	}
+	writeRekening(w, pekerjaID, "Rekening berhasil diverifikasi")
+}
+
+// handleWithdraw mencairkan saldo MyPay pekerja ke rekening bank. Pencairan
+// hanya diizinkan ke rekening yang sudah terverifikasi.
+func handleWithdraw(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var transaction WithdrawRequestBody
+	err := json.NewDecoder(r.Body).Decode(&transaction)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	transaction.UserID = currentUserID(r)
+
+	if transaction.Nominal <= 0 {
+		http.Error(w, "Nominal harus lebih dari 0", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(transaction.KategoriID); err != nil {
+		http.Error(w, "Invalid kategori_id format", http.StatusBadRequest)
+		return
+	}
+	pencairan, err := kategoriPencairan(db, transaction.KategoriID)
+	if err == sql.ErrNoRows || (err == nil && !pencairan) {
+		http.Error(w, "Kategori bukan kategori penarikan saldo", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var saldo float64
+	var status string
+	err = tx.QueryRow(`SELECT u.SaldoMyPay, p.RekeningStatus
+	FROM "user" u
+	JOIN PEKERJA p ON p.Id = u.Id
+	WHERE u.Id = $1
+	FOR UPDATE OF u, p`, transaction.UserID).Scan(&saldo, &status)
+	if err != nil {
+		http.Error(w, "Failed to fetch user balance", http.StatusInternalServerError)
+		return
+	}
+
+	if status != rekeningVerified {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"message": errRekeningNotVerified.Error()})
+		return
+	}
+
+	if saldo < transaction.Nominal {
+		json.NewEncoder(w).Encode(map[string]string{"message": "Saldo tidak mencukupi untuk melakukan penarikan."})
+		return
+	}
+
+	_, err = tx.Exec(`UPDATE "user" SET SaldoMyPay = SaldoMyPay - $1 WHERE Id = $2`, transaction.Nominal, transaction.UserID)
+	if err != nil {
+		http.Error(w, "Failed to update user balance", http.StatusInternalServerError)
+		return
+	}
+
+	location, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	date := time.Now().In(location).Format("2006-01-02")
+
+	_, err = tx.Exec(`INSERT
+	INTO TR_MYPAY (Id, UserId, Tgl, Nominal, KategoriId) VALUES ($1, $2, $3, $4, $5)`,
+		uuid.New(), transaction.UserID, date, transaction.Nominal, transaction.KategoriID)
+	if err != nil {
+		http.Error(w, "Failed to record withdrawal transaction", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Withdrawal successful"})
+}
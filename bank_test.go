@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestSameHolderName(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"BUDI SANTOSO", "Budi Santoso", true},
+		{"BUDI  SANTOSO", " budi santoso ", true},
+		{"BUDI SANTOSO", "Budi Santosa", false},
+		{"BUDI", "Budi Santoso", false},
+		{"PEMILIK LAIN", "Budi Santoso", false},
+		{"", "", false},
+		{"   ", "", false},
+	}
+	for _, tt := range tests {
+		if got := sameHolderName(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameHolderName(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+)
+
+// Struct di bawah adalah skema JSON /homepage yang dipakai frontend. Slice
+// selalu diisi (bukan null) agar client tidak perlu memeriksa nil.
+type HomepageSesi struct {
+	Id    int     `json:"id"`
+	Nama  string  `json:"nama"`
+	Harga float64 `json:"harga"`
+}
+
+type HomepageSubkategori struct {
+	Id         string         `json:"id"`
+	Nama       string         `json:"nama"`
+	Deskripsi  string         `json:"deskripsi"`
+	HargaMulai *float64       `json:"hargaMulai"`
+	Sesi       []HomepageSesi `json:"sesi"`
+}
+
+type HomepageKategori struct {
+	Id          string                `json:"id"`
+	Nama        string                `json:"nama"`
+	Subkategori []HomepageSubkategori `json:"subkategori"`
+}
+
+type HomepageResponseBody struct {
+	Status   bool               `json:"status"`
+	Message  string             `json:"message"`
+	Kategori []HomepageKategori `json:"kategori"`
+}
+
+// loadHomepageCatalog membaca seluruh kategori beserta subkategori dan sesinya
+// dalam satu query. Kolom hasil LEFT JOIN bisa NULL untuk kategori tanpa
+// subkategori atau subkategori tanpa sesi. Item yang diarsipkan dilewati.
+func loadHomepageCatalog(q sqlQuerier) ([]HomepageKategori, error) {
+	rows, err := q.Query(`
+		SELECT k.Id, k.NamaKategori, s.Id, s.NamaSubkategori, s.Deskripsi, sesi.id, sesi.nama_sesi, sesi.harga
+		FROM KATEGORI_JASA k
+		LEFT JOIN SUBKATEGORI_JASA s ON k.Id = s.KategoriJasaId AND s.ArchivedAt IS NULL
+		LEFT JOIN sesi_layanan sesi ON s.Id = sesi.id_subkategori AND sesi.archived_at IS NULL
+		WHERE k.ArchivedAt IS NULL
+		ORDER BY k.Urutan, k.NamaKategori, k.Id, s.Urutan, s.NamaSubkategori, s.Id, sesi.urutan, sesi.harga, sesi.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kategori := []HomepageKategori{}
+	for rows.Next() {
+		var kategoriID, kategoriNama string
+		var subID, subNama, subDeskripsi, sesiNama sql.NullString
+		var sesiID sql.NullInt64
+		var harga sql.NullFloat64
+		err := rows.Scan(&kategoriID, &kategoriNama, &subID, &subNama, &subDeskripsi, &sesiID, &sesiNama, &harga)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(kategori) == 0 || kategori[len(kategori)-1].Id != kategoriID {
+			kategori = append(kategori, HomepageKategori{
+				Id:          kategoriID,
+				Nama:        kategoriNama,
+				Subkategori: []HomepageSubkategori{},
+			})
+		}
+		k := &kategori[len(kategori)-1]
+		if !subID.Valid {
+			continue
+		}
+
+		if len(k.Subkategori) == 0 || k.Subkategori[len(k.Subkategori)-1].Id != subID.String {
+			k.Subkategori = append(k.Subkategori, HomepageSubkategori{
+				Id:        subID.String,
+				Nama:      subNama.String,
+				Deskripsi: subDeskripsi.String,
+				Sesi:      []HomepageSesi{},
+			})
+		}
+		sub := &k.Subkategori[len(k.Subkategori)-1]
+		if !sesiID.Valid {
+			continue
+		}
+
+		sub.Sesi = append(sub.Sesi, HomepageSesi{
+			Id:    int(sesiID.Int64),
+			Nama:  sesiNama.String,
+			Harga: harga.Float64,
+		})
+		if sub.HargaMulai == nil || harga.Float64 < *sub.HargaMulai {
+			hargaMulai := harga.Float64
+			sub.HargaMulai = &hargaMulai
+		}
+	}
+	return kategori, rows.Err()
+}
+
+// Get Homepage (all categories and subcategories)
+func getHomepage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := serveCatalogJSON(w, r, "homepage", func() ([]byte, error) {
+		kategori, err := loadHomepageCatalog(db)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(&HomepageResponseBody{
+			Status:   true,
+			Message:  "Berhasil mendapatkan data",
+			Kategori: kategori,
+		})
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&HomepageResponseBody{
+			Status:   false,
+			Message:  err.Error(),
+			Kategori: []HomepageKategori{},
+		})
+	}
+}
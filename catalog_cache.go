@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const catalogCacheControl = "public, max-age=60, must-revalidate"
+
+// catalogCacheTTL membatasi umur entry karena invalidate hanya berlaku di
+// instance yang menerima perubahan. Perubahan dari instance lain atau
+// langsung di tabel terlihat paling lambat setelah TTL ini.
+const catalogCacheTTL = time.Minute
+
+// catalogCacheMaxEntries membatasi jumlah entry karena key ikut ditentukan
+// parameter dari client, misalnya id subkategori.
+const catalogCacheMaxEntries = 1000
+
+type catalogCacheEntry struct {
+	Body     []byte
+	ETag     string
+	LoadedAt time.Time
+}
+
+// catalogCache menyimpan response katalog yang sudah di-encode. Setiap
+// perubahan katalog menaikkan version dan mengosongkan isi cache, sehingga
+// hasil load yang dimulai sebelum invalidasi tidak ikut disimpan.
+type catalogCache struct {
+	mu      sync.RWMutex
+	version uint64
+	entries map[string]catalogCacheEntry
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+}
+
+type CatalogCacheStats struct {
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hitRatio"`
+	Entries  int     `json:"entries"`
+}
+
+type CatalogCacheStatsResponseBody struct {
+	Status  bool              `json:"status"`
+	Message string            `json:"message"`
+	Cache   CatalogCacheStats `json:"cache"`
+}
+
+var catalogResponses = &catalogCache{entries: map[string]catalogCacheEntry{}}
+
+// get mengembalikan entry untuk key, memanggil load jika belum ada atau sudah
+// melewati catalogCacheTTL. Error dari load, termasuk sql.ErrNoRows untuk data
+// yang tidak ada, tidak disimpan.
+func (c *catalogCache) get(key string, load func() ([]byte, error)) (catalogCacheEntry, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	version := c.version
+	c.mu.RUnlock()
+	if ok && time.Since(entry.LoadedAt) < catalogCacheTTL {
+		c.hits.Add(1)
+		return entry, nil
+	}
+	c.misses.Add(1)
+
+	body, err := load()
+	if err != nil {
+		return catalogCacheEntry{}, err
+	}
+	sum := sha256.Sum256(body)
+	entry = catalogCacheEntry{
+		Body:     body,
+		ETag:     `"` + hex.EncodeToString(sum[:16]) + `"`,
+		LoadedAt: time.Now(),
+	}
+
+	c.mu.Lock()
+	if c.version == version {
+		c.store(key, entry)
+	}
+	c.mu.Unlock()
+	return entry, nil
+}
+
+// store dipanggil dengan mu terkunci. Entry kedaluwarsa dibuang saat cache
+// penuh, dan jika masih penuh entry baru tidak disimpan.
+func (c *catalogCache) store(key string, entry catalogCacheEntry) {
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= catalogCacheMaxEntries {
+		for k, e := range c.entries {
+			if time.Since(e.LoadedAt) >= catalogCacheTTL {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= catalogCacheMaxEntries {
+			return
+		}
+	}
+	c.entries[key] = entry
+}
+
+func (c *catalogCache) invalidate() {
+	c.mu.Lock()
+	c.version++
+	c.entries = map[string]catalogCacheEntry{}
+	c.mu.Unlock()
+}
+
+func (c *catalogCache) stats() CatalogCacheStats {
+	c.mu.RLock()
+	entries := len(c.entries)
+	c.mu.RUnlock()
+
+	stats := CatalogCacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: entries,
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// etagMatches memeriksa header If-None-Match yang bisa berisi beberapa ETag,
+// ETag lemah (W/), atau "*".
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// serveCatalogJSON menulis response katalog dari cache beserta ETag dan
+// Cache-Control, atau 304 jika client sudah memegang versi yang sama.
+func serveCatalogJSON(w http.ResponseWriter, r *http.Request, key string, load func() ([]byte, error)) error {
+	entry, err := catalogResponses.get(key, load)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", entry.ETag)
+	w.Header().Set("Cache-Control", catalogCacheControl)
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, entry.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(entry.Body)
+	return nil
+}
+
+func getCatalogCacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&CatalogCacheStatsResponseBody{
+		Status:  true,
+		Message: "Berhasil mendapatkan data",
+		Cache:   catalogResponses.stats(),
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEtagMatches(t *testing.T) {
+	const etag = `"abc123"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"abc123"`, true},
+		{`W/"abc123"`, true},
+		{`"lama", "abc123"`, true},
+		{` "lama" ,W/"abc123" `, true},
+		{`*`, true},
+		{`"lama"`, false},
+		{`abc123`, false},
+		{`"abc1234"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogCacheGet(t *testing.T) {
+	cache := &catalogCache{entries: map[string]catalogCacheEntry{}}
+	loads := 0
+	load := func() ([]byte, error) {
+		loads++
+		return []byte(`[]`), nil
+	}
+
+	first, _ := cache.get("kategori", load)
+	second, _ := cache.get("kategori", load)
+	if loads != 1 || first.ETag != second.ETag {
+		t.Fatalf("entry baru harus diambil dari cache, load dipanggil %d kali", loads)
+	}
+
+	// Entry yang melewati TTL dimuat ulang
+	entry := cache.entries["kategori"]
+	entry.LoadedAt = time.Now().Add(-catalogCacheTTL)
+	cache.entries["kategori"] = entry
+	cache.get("kategori", load)
+	if loads != 2 {
+		t.Errorf("entry kedaluwarsa harus dimuat ulang, load dipanggil %d kali", loads)
+	}
+
+	cache.invalidate()
+	cache.get("kategori", load)
+	if loads != 3 {
+		t.Errorf("invalidate harus mengosongkan cache, load dipanggil %d kali", loads)
+	}
+}
+
+func TestCatalogCacheStoreLimit(t *testing.T) {
+	cache := &catalogCache{entries: map[string]catalogCacheEntry{}}
+	for i := 0; i < catalogCacheMaxEntries; i++ {
+		cache.store(strconv.Itoa(i), catalogCacheEntry{LoadedAt: time.Now()})
+	}
+
+	cache.store("baru", catalogCacheEntry{LoadedAt: time.Now()})
+	if _, ok := cache.entries["baru"]; ok || len(cache.entries) != catalogCacheMaxEntries {
+		t.Fatalf("cache penuh tidak boleh bertambah, jumlah entry %d", len(cache.entries))
+	}
+
+	// Entry kedaluwarsa dibuang untuk memberi tempat
+	cache.entries["0"] = catalogCacheEntry{LoadedAt: time.Now().Add(-catalogCacheTTL)}
+	cache.store("baru", catalogCacheEntry{LoadedAt: time.Now()})
+	if _, ok := cache.entries["baru"]; !ok {
+		t.Errorf("entry baru harus disimpan setelah entry kedaluwarsa dibuang")
+	}
+	if _, ok := cache.entries["0"]; ok {
+		t.Errorf("entry kedaluwarsa harus dibuang")
+	}
+}
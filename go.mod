@@ -4,6 +4,8 @@ go 1.21.3
 
 require (
 	github.com/google/uuid v1.6.0
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/rs/cors v1.11.1 // indirect
+	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.21.0
 )
+
+require github.com/rs/cors v1.11.1 // indirect
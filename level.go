@@ -0,0 +1,235 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LevelTier adalah satu tingkat di LEVEL_PELANGGAN. Pelanggan naik ke tier
+// tertinggi yang syarat pesanan selesai dan total belanjanya terpenuhi.
+type LevelTier struct {
+	Nama                string  `json:"nama"`
+	Urutan              int     `json:"urutan"`
+	MinPesananSelesai   int     `json:"minPesananSelesai"`
+	MinTotalBelanja     float64 `json:"minTotalBelanja"`
+	BisaBeliVoucher     bool    `json:"bisaBeliVoucher"`
+	DiskonVoucherPersen float64 `json:"diskonVoucherPersen"`
+}
+
+type LevelHistoryItem struct {
+	LevelLama      string    `json:"levelLama"`
+	LevelBaru      string    `json:"levelBaru"`
+	PesananSelesai int       `json:"pesananSelesai"`
+	TotalBelanja   float64   `json:"totalBelanja"`
+	Tgl            time.Time `json:"tgl"`
+}
+
+type GetLevelResponseBody struct {
+	Status         bool               `json:"status"`
+	Message        string             `json:"message"`
+	Level          *LevelTier         `json:"level,omitempty"`
+	LevelBerikut   *LevelTier         `json:"levelBerikut,omitempty"`
+	PesananSelesai int                `json:"pesananSelesai"`
+	TotalBelanja   float64            `json:"totalBelanja"`
+	Riwayat        []LevelHistoryItem `json:"riwayat"`
+}
+
+func loadLevelTiers(q sqlQuerier) ([]LevelTier, error) {
+	rows, err := q.Query(`SELECT Nama, Urutan, MinPesananSelesai, MinTotalBelanja, BisaBeliVoucher, DiskonVoucherPersen
+	FROM LEVEL_PELANGGAN ORDER BY Urutan`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tiers []LevelTier
+	for rows.Next() {
+		var tier LevelTier
+		err := rows.Scan(
+			&tier.Nama,
+			&tier.Urutan,
+			&tier.MinPesananSelesai,
+			&tier.MinTotalBelanja,
+			&tier.BisaBeliVoucher,
+			&tier.DiskonVoucherPersen)
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, rows.Err()
+}
+
+func findLevelTier(tiers []LevelTier, nama string) *LevelTier {
+	for i := range tiers {
+		if tiers[i].Nama == nama {
+			return &tiers[i]
+		}
+	}
+	return nil
+}
+
+// matchLevelTier memilih tier tertinggi yang syaratnya terpenuhi. tiers harus
+// sudah terurut naik berdasarkan Urutan.
+func matchLevelTier(tiers []LevelTier, pesananSelesai int, totalBelanja float64) *LevelTier {
+	var matched *LevelTier
+	for i := range tiers {
+		if pesananSelesai >= tiers[i].MinPesananSelesai && totalBelanja >= tiers[i].MinTotalBelanja {
+			matched = &tiers[i]
+		}
+	}
+	return matched
+}
+
+func pelangganOrderStats(q sqlQuerier, pelangganID string) (int, float64, error) {
+	var pesananSelesai int
+	var totalBelanja float64
+	err := q.QueryRow(`SELECT COUNT(*), COALESCE(SUM(tpj.TotalBiaya), 0)
+	FROM TR_PEMESANAN_JASA tpj
+	WHERE tpj.IdPelanggan = $1
+	AND EXISTS (
+		SELECT 1 FROM TR_PEMESANAN_STATUS tps
+		JOIN STATUS_PESANAN sp ON sp.Id = tps.IdStatus
+		WHERE tps.IdTrPemesanan = tpj.Id AND sp.Status = 'Pesanan selesai'
+	)`, pelangganID).Scan(&pesananSelesai, &totalBelanja)
+	return pesananSelesai, totalBelanja, err
+}
+
+// evaluateCustomerLevel menghitung ulang level pelanggan dari pesanan yang
+// sudah selesai dan mencatat riwayat jika levelnya berubah.
+func evaluateCustomerLevel(tx sqlQuerier, pelangganID string) error {
+	var currentLevel string
+	err := tx.QueryRow(`SELECT Level FROM PELANGGAN WHERE Id = $1 FOR UPDATE`, pelangganID).Scan(&currentLevel)
+	if err != nil {
+		return err
+	}
+
+	tiers, err := loadLevelTiers(tx)
+	if err != nil {
+		return err
+	}
+
+	pesananSelesai, totalBelanja, err := pelangganOrderStats(tx, pelangganID)
+	if err != nil {
+		return err
+	}
+
+	tier := matchLevelTier(tiers, pesananSelesai, totalBelanja)
+	if tier == nil || tier.Nama == currentLevel {
+		return nil
+	}
+
+	_, err = tx.Exec(`UPDATE PELANGGAN SET Level = $1 WHERE Id = $2`, tier.Nama, pelangganID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO PELANGGAN_LEVEL_HISTORY
+	(Id, PelangganId, LevelLama, LevelBaru, PesananSelesai, TotalBelanja, Tgl)
+	VALUES ($1, $2, $3, $4, $5, $6, NOW())`,
+		uuid.New(), pelangganID, currentLevel, tier.Nama, pesananSelesai, totalBelanja)
+	return err
+}
+
+// pelangganLevelBenefit mengembalikan tier yang sedang dimiliki pelanggan.
+func pelangganLevelBenefit(db *sql.DB, pelangganID string) (*LevelTier, error) {
+	tier := &LevelTier{}
+	err := db.QueryRow(`SELECT l.Nama, l.Urutan, l.MinPesananSelesai, l.MinTotalBelanja, l.BisaBeliVoucher, l.DiskonVoucherPersen
+	FROM PELANGGAN p
+	JOIN LEVEL_PELANGGAN l ON l.Nama = p.Level
+	WHERE p.Id = $1`, pelangganID).Scan(
+		&tier.Nama,
+		&tier.Urutan,
+		&tier.MinPesananSelesai,
+		&tier.MinTotalBelanja,
+		&tier.BisaBeliVoucher,
+		&tier.DiskonVoucherPersen)
+	if err != nil {
+		return nil, err
+	}
+	return tier, nil
+}
+
+func getPelangganLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := currentUserID(r)
+
+	var currentLevel string
+	err := db.QueryRow(`SELECT Level FROM PELANGGAN WHERE Id = $1`, userID).Scan(&currentLevel)
+	if err != nil {
+		json.NewEncoder(w).Encode(&GetLevelResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	tiers, err := loadLevelTiers(db)
+	if err != nil {
+		json.NewEncoder(w).Encode(&GetLevelResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	pesananSelesai, totalBelanja, err := pelangganOrderStats(db, userID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&GetLevelResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response := &GetLevelResponseBody{
+		Status:         true,
+		Message:        "Berhasil mendapatkan data",
+		Level:          findLevelTier(tiers, currentLevel),
+		PesananSelesai: pesananSelesai,
+		TotalBelanja:   totalBelanja,
+		Riwayat:        []LevelHistoryItem{},
+	}
+	if response.Level != nil {
+		for i := range tiers {
+			if tiers[i].Urutan > response.Level.Urutan {
+				response.LevelBerikut = &tiers[i]
+				break
+			}
+		}
+	}
+
+	rows, err := db.Query(`SELECT LevelLama, LevelBaru, PesananSelesai, TotalBelanja, Tgl
+	FROM PELANGGAN_LEVEL_HISTORY WHERE PelangganId = $1 ORDER BY Tgl DESC`, userID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&GetLevelResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item LevelHistoryItem
+		err := rows.Scan(&item.LevelLama, &item.LevelBaru, &item.PesananSelesai, &item.TotalBelanja, &item.Tgl)
+		if err != nil {
+			json.NewEncoder(w).Encode(&GetLevelResponseBody{
+				Status:  false,
+				Message: err.Error(),
+			})
+			return
+		}
+		response.Riwayat = append(response.Riwayat, item)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestMatchLevelTier(t *testing.T) {
+	tiers := []LevelTier{
+		{Nama: "Basic", Urutan: 1},
+		{Nama: "Silver", Urutan: 2, MinPesananSelesai: 5, MinTotalBelanja: 500000},
+		{Nama: "Gold", Urutan: 3, MinPesananSelesai: 15, MinTotalBelanja: 2000000},
+	}
+
+	tests := []struct {
+		name           string
+		pesananSelesai int
+		totalBelanja   float64
+		want           string
+	}{
+		{"pelanggan baru", 0, 0, "Basic"},
+		{"pesanan cukup, belanja kurang", 5, 499999, "Basic"},
+		{"belanja cukup, pesanan kurang", 4, 1000000, "Basic"},
+		{"tepat di batas silver", 5, 500000, "Silver"},
+		{"syarat gold hanya sebagian", 20, 1500000, "Silver"},
+		{"tepat di batas gold", 15, 2000000, "Gold"},
+		{"jauh di atas gold", 100, 50000000, "Gold"},
+	}
+	for _, tt := range tests {
+		got := matchLevelTier(tiers, tt.pesananSelesai, tt.totalBelanja)
+		if got == nil || got.Nama != tt.want {
+			t.Errorf("%s: matchLevelTier(%d, %v) = %v, want %s", tt.name, tt.pesananSelesai, tt.totalBelanja, got, tt.want)
+		}
+	}
+}
+
+func TestMatchLevelTierWithoutMatch(t *testing.T) {
+	tiers := []LevelTier{
+		{Nama: "Silver", Urutan: 1, MinPesananSelesai: 5},
+	}
+	if got := matchLevelTier(tiers, 0, 0); got != nil {
+		t.Errorf("matchLevelTier = %v, want nil", got)
+	}
+	if got := matchLevelTier(nil, 10, 1000000); got != nil {
+		t.Errorf("matchLevelTier tanpa tier = %v, want nil", got)
+	}
+}
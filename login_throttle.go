@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// loginThrottlePolicy mengatur kapan percobaan login mulai diperlambat dan
+// kapan percobaan dengan kunci tersebut diblokir sementara.
+type loginThrottlePolicy struct {
+	prefix     string
+	delayAfter int
+	lockAfter  int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	lockout    time.Duration
+	window     time.Duration
+}
+
+var (
+	phoneLoginPolicy = loginThrottlePolicy{
+		prefix:     "nohp:",
+		delayAfter: 3,
+		lockAfter:  10,
+		baseDelay:  time.Second,
+		maxDelay:   time.Minute,
+		lockout:    15 * time.Minute,
+		window:     time.Hour,
+	}
+	ipLoginPolicy = loginThrottlePolicy{
+		prefix:     "ip:",
+		delayAfter: 10,
+		lockAfter:  50,
+		baseDelay:  time.Second,
+		maxDelay:   time.Minute,
+		lockout:    30 * time.Minute,
+		window:     time.Hour,
+	}
+)
+
+// blockDuration menghitung berapa lama kunci diblokir setelah sejumlah
+// kegagalan berturut-turut.
+func (p loginThrottlePolicy) blockDuration(failures int) time.Duration {
+	if failures >= p.lockAfter {
+		return p.lockout
+	}
+	if failures <= p.delayAfter {
+		return 0
+	}
+
+	delay := p.baseDelay << uint(failures-p.delayAfter-1)
+	if delay > p.maxDelay || delay <= 0 {
+		return p.maxDelay
+	}
+	return delay
+}
+
+// loginRetryAt mengembalikan waktu paling awal pemanggil boleh mencoba login
+// lagi. Nilai nol berarti tidak sedang diblokir.
+func loginRetryAt(db *sql.DB, noHP, ip string) (time.Time, error) {
+	var blockedUntil sql.NullTime
+	err := db.QueryRow(`SELECT MAX(BlockedUntil) FROM LOGIN_ATTEMPT
+	WHERE AttemptKey IN ($1, $2) AND BlockedUntil > NOW()`,
+		phoneLoginPolicy.prefix+noHP, ipLoginPolicy.prefix+ip).Scan(&blockedUntil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return blockedUntil.Time, nil
+}
+
+func recordLoginAttemptFailure(db *sql.DB, policy loginThrottlePolicy, key string) (time.Time, error) {
+	now := time.Now()
+
+	var failures int
+	err := db.QueryRow(`INSERT INTO LOGIN_ATTEMPT (AttemptKey, Failures, LastFailureAt)
+	VALUES ($1, 1, $2)
+	ON CONFLICT (AttemptKey) DO UPDATE SET
+	Failures = CASE WHEN LOGIN_ATTEMPT.LastFailureAt < $3 THEN 1 ELSE LOGIN_ATTEMPT.Failures + 1 END,
+	LastFailureAt = EXCLUDED.LastFailureAt
+	RETURNING Failures`, policy.prefix+key, now, now.Add(-policy.window)).Scan(&failures)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	block := policy.blockDuration(failures)
+	if block == 0 {
+		return time.Time{}, nil
+	}
+
+	blockedUntil := now.Add(block)
+	_, err = db.Exec(`UPDATE LOGIN_ATTEMPT SET BlockedUntil = $1 WHERE AttemptKey = $2`, blockedUntil, policy.prefix+key)
+	return blockedUntil, err
+}
+
+// recordLoginFailure mencatat kegagalan untuk nomor HP dan IP sekaligus lalu
+// mengembalikan waktu blokir yang paling lama.
+func recordLoginFailure(db *sql.DB, noHP, ip string) (time.Time, error) {
+	phoneBlock, err := recordLoginAttemptFailure(db, phoneLoginPolicy, noHP)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ipBlock, err := recordLoginAttemptFailure(db, ipLoginPolicy, ip)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if ipBlock.After(phoneBlock) {
+		return ipBlock, nil
+	}
+	return phoneBlock, nil
+}
+
+// clearLoginFailures hanya menghapus hitungan untuk nomor HP. Hitungan per IP
+// dibiarkan agar login sukses ke akun sendiri tidak mereset tebakan ke akun lain.
+func clearLoginFailures(db *sql.DB, noHP string) error {
+	_, err := db.Exec(`DELETE FROM LOGIN_ATTEMPT WHERE AttemptKey = $1`, phoneLoginPolicy.prefix+noHP)
+	return err
+}
+
+// loginFailureResponse mencatat kegagalan login lalu mengirim respons gagal
+// beserta waktu pemanggil boleh mencoba lagi jika sudah diblokir.
+func loginFailureResponse(w http.ResponseWriter, r *http.Request, noHP, message string) {
+	retryAt, err := recordLoginFailure(db, noHP, clientIP(r))
+	if err != nil {
+		log.Printf("Error recording login failure: %v", err)
+	}
+
+	response := &LoginResponseBody{
+		Status:  false,
+		Message: message,
+	}
+	if !retryAt.IsZero() {
+		response.RetryAfter = &retryAt
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeLoginThrottled(w http.ResponseWriter, retryAt time.Time) {
+	seconds := int(math.Ceil(time.Until(retryAt).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	json.NewEncoder(w).Encode(&LoginResponseBody{
+		Status:     false,
+		Message:    "Terlalu banyak percobaan login, coba lagi nanti",
+		RetryAfter: &retryAt,
+	})
+}
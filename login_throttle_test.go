@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlockDuration(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{9, 32 * time.Second},
+		{10, 15 * time.Minute},
+		{25, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := phoneLoginPolicy.blockDuration(tt.failures); got != tt.want {
+			t.Errorf("blockDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestBlockDurationCapsAtMaxDelay(t *testing.T) {
+	policy := loginThrottlePolicy{
+		delayAfter: 0,
+		lockAfter:  1000,
+		baseDelay:  time.Second,
+		maxDelay:   time.Minute,
+		lockout:    time.Hour,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		// Shift sebesar ini meluap dan tidak boleh menghasilkan nilai negatif
+		{100, time.Minute},
+		{999, time.Minute},
+		{1000, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := policy.blockDuration(tt.failures); got != tt.want {
+			t.Errorf("blockDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
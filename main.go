@@ -3,13 +3,16 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const (
@@ -28,32 +31,36 @@ type LoginRequestBody struct {
 }
 
 type LoginResponseBody struct {
-	Status  bool   `json:"status"`
-	Role    int    `json:"role"`
-	UserId  string `json:"userId"`
-	Message string `json:"message"`
-	Name    string `json:"name"`
+	Status       bool       `json:"status"`
+	Role         int        `json:"role"`
+	UserId       string     `json:"userId"`
+	Message      string     `json:"message"`
+	Name         string     `json:"name"`
+	AccessToken  string     `json:"accessToken,omitempty"`
+	RefreshToken string     `json:"refreshToken,omitempty"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	RoleName     Role       `json:"roleName,omitempty"`
+	RetryAfter   *time.Time `json:"retryAfter,omitempty"`
 }
 
 type RegisterRequestBody struct {
-	Role              int       `json:"role"`
-	Nama              string    `json:"name"`
-	JenisKelamin      string    `json:"sex"`
-	NoHP              string    `json:"number"`
-	Pwd               string    `json:"password"`
-	TglLahir          time.Time `json:"date"`
-	Alamat            string    `json:"address"`
-	NamaBank          string    `json:"bank"`
-	NomorRekening     string    `json:"noRek"`
-	NPWP              string    `json:"npwp"`
-	LinkFoto          string    `json:"link"`
-	Rating            float64   `json:"rating"`
-	JmlPsnananSelesai int       `json:"amount"`
+	Role          int       `json:"role"`
+	Nama          string    `json:"name"`
+	JenisKelamin  string    `json:"sex"`
+	NoHP          string    `json:"number"`
+	Pwd           string    `json:"password"`
+	TglLahir      time.Time `json:"date"`
+	Alamat        string    `json:"address"`
+	NamaBank      string    `json:"bank"`
+	NomorRekening string    `json:"noRek"`
+	NPWP          string    `json:"npwp"`
+	OTP           string    `json:"otp"`
 }
 
 type RegisterResponseBody struct {
-	Message string `json:"message"`
-	Status  bool   `json:"status"`
+	Message string      `json:"message"`
+	Status  bool        `json:"status"`
+	Errors  FieldErrors `json:"errors,omitempty"`
 }
 
 type GetUserRequestBody struct {
@@ -62,44 +69,33 @@ type GetUserRequestBody struct {
 }
 
 type GetUserResponseBody struct {
-	Status              bool      `json:"status"`
-	Message             string    `json:"message"`
-	User                string    `json:"userid"`
-	Role                int       `json:"role"`
-	Nama                string    `json:"name"`
-	JenisKelamin        string    `json:"sex"`
-	NoHP                string    `json:"number"`
-	Pwd                 string    `json:"password"`
-	TglLahir            time.Time `json:"date"`
-	Alamat              string    `json:"address"`
-	SaldoMyPay          float64   `json:"saldo"`
-	Level               string    `json:"level"`
-	NamaBank            string    `json:"bank"`
-	NomorRekening       string    `json:"noRek"`
-	NPWP                string    `json:"npwp"`
-	LinkFoto            string    `json:"link"`
-	Rating              float64   `json:"rating"`
-	JmlPsnananSelesai   int       `json:"amount"`
-	PekerjaKategoriJasa []string  `json:"pekerjakategorijasa"`
-}
-
-type UpdateUserRequestBody struct {
-	User          string    `json:"user"`
-	Role          int       `json:"role"`
-	Nama          string    `json:"name"`
-	JenisKelamin  string    `json:"sex"`
-	NoHP          string    `json:"number"`
-	TglLahir      time.Time `json:"date"`
-	Alamat        string    `json:"address"`
-	NamaBank      string    `json:"bank"`
-	NomorRekening string    `json:"noRek"`
-	NPWP          string    `json:"npwp"`
-	LinkFoto      string    `json:"link"`
+	Status              bool        `json:"status"`
+	Message             string      `json:"message"`
+	User                string      `json:"userid"`
+	Role                int         `json:"role"`
+	Nama                string      `json:"name"`
+	JenisKelamin        string      `json:"sex"`
+	NoHP                string      `json:"number"`
+	TglLahir            time.Time   `json:"date"`
+	Alamat              string      `json:"address"`
+	SaldoMyPay          float64     `json:"saldo"`
+	Level               string      `json:"level"`
+	NamaBank            string      `json:"bank"`
+	NomorRekening       string      `json:"noRek"`
+	NPWP                string      `json:"npwp"`
+	LinkFoto            string      `json:"link"`
+	LinkFotoThumbnail   string      `json:"thumbnail"`
+	Rating              float64     `json:"rating"`
+	JmlPsnananSelesai   int         `json:"amount"`
+	PekerjaKategoriJasa []string    `json:"pekerjakategorijasa"`
+	AlamatDefault       *AlamatItem `json:"defaultAddress,omitempty"`
 }
 
 type UpdateUserResponseBody struct {
-	Message string `json:"message"`
-	Status  bool   `json:"status"`
+	Message string               `json:"message"`
+	Status  bool                 `json:"status"`
+	Errors  FieldErrors          `json:"errors,omitempty"`
+	Profile *GetUserResponseBody `json:"profile,omitempty"`
 }
 
 // BAGIAN MERAH
@@ -168,6 +164,10 @@ type JobsData struct {
 	NamaPelanggan   string    `json:"nama"`
 	Sesi            int       `json:"sesi"`
 	Total           float64   `json:"total"`
+	Alamat          string    `json:"alamat"`
+	Latitude        *float64  `json:"latitude"`
+	Longitude       *float64  `json:"longitude"`
+	Jarak           *float64  `json:"jarak,omitempty"`
 }
 
 type GetJobsResponse struct {
@@ -199,6 +199,9 @@ type JobsDataDemand struct {
 	NamaPelanggan   string    `json:"nama"`
 	Sesi            int       `json:"sesi"`
 	Total           float64   `json:"total"`
+	Alamat          string    `json:"alamat"`
+	Latitude        *float64  `json:"latitude"`
+	Longitude       *float64  `json:"longitude"`
 	Status          int       `json:"status"`
 }
 
@@ -261,6 +264,15 @@ type BuyVoucherResponse struct {
 }
 
 func main() {
+	backfillRatings := flag.Bool("backfill-ratings", false, "hitung ulang rating semua pekerja dari testimoni lalu keluar")
+	flag.Parse()
+
+	secret, err := loadAuthSecret()
+	if err != nil {
+		log.Fatalf("Error loading auth secret: %v", err)
+	}
+	authSecret = secret
+
 	pgConnStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
 
 	conn, err := sql.Open("postgres", pgConnStr)
@@ -276,41 +288,105 @@ func main() {
 	}
 	fmt.Println("Connected to the PostgreSQL database")
 
+	if err := migrateSchema(db); err != nil {
+		log.Fatalf("Error migrating database schema: %v", err)
+	}
+	searchTrigram = setupTrigramSearch(db)
+
+	if *backfillRatings {
+		count, err := backfillPekerjaRatings(db)
+		if err != nil {
+			log.Fatalf("Error backfilling pekerja ratings: %v", err)
+		}
+		fmt.Printf("Rating %d pekerja berhasil dihitung ulang\n", count)
+		return
+	}
+
 	// tambah endpoint disini
 	http.HandleFunc("/login", corsMiddleware(checkLogin))
 	http.HandleFunc("/register", corsMiddleware(register))
-	http.HandleFunc("/getUser", corsMiddleware(getUser))
-	http.HandleFunc("/updateUser", corsMiddleware(updateUser))
+	http.HandleFunc("/register/otp", corsMiddleware(requestRegisterOTP))
+	http.HandleFunc("/register/verify", corsMiddleware(verifyRegistration))
+	http.HandleFunc("/password/forgot", corsMiddleware(forgotPassword))
+	http.HandleFunc("/password/reset", corsMiddleware(resetPassword))
+	http.HandleFunc("/password/change", corsMiddleware(authMiddleware(changePassword)))
+	http.HandleFunc("/account/deactivate", corsMiddleware(authMiddleware(deactivateAccount)))
+	http.HandleFunc("/account/erase", corsMiddleware(authMiddleware(eraseAccount)))
+	http.HandleFunc("/refresh", corsMiddleware(refreshHandler))
+	http.HandleFunc("/logout", corsMiddleware(authMiddleware(logoutHandler)))
+	http.HandleFunc("/sessions", corsMiddleware(authMiddleware(getSessionsHandler)))
+	http.HandleFunc("/sessions/revoke", corsMiddleware(authMiddleware(revokeSessionHandler)))
+	http.HandleFunc("/getUser", corsMiddleware(authMiddleware(getUser)))
+	http.HandleFunc("/updateUser", corsMiddleware(authMiddleware(updateUser)))
+	http.HandleFunc("/updateUser/number/verify", corsMiddleware(authMiddleware(verifyNoHPChange)))
+	http.HandleFunc("/alamat", corsMiddleware(authMiddleware(getAlamat)))
+	http.HandleFunc("/alamat/add", corsMiddleware(authMiddleware(addAlamat)))
+	http.HandleFunc("/alamat/update", corsMiddleware(authMiddleware(updateAlamat)))
+	http.HandleFunc("/alamat/default", corsMiddleware(authMiddleware(setDefaultAlamatHandler)))
+	http.HandleFunc("/alamat/remove", corsMiddleware(authMiddleware(removeAlamat)))
 	http.HandleFunc("/homepage", getHomepage)
 	http.HandleFunc("/subkategori", getSubkategori)
-	http.HandleFunc("/pesan", createPesanan)
-
-	http.HandleFunc("/mypay/balance", corsMiddleware(getMyPayBalance))
-	http.HandleFunc("/mypay/history", corsMiddleware(getMyPayHistory))
-	http.HandleFunc("/mypay/topup", corsMiddleware(handleTopUp))
-	http.HandleFunc("/mypay/transfer", corsMiddleware(handleTransfer))
+	http.HandleFunc("/subkategori/detail", getSubkategoriDetail)
+	http.HandleFunc("/search", corsMiddleware(searchLayanan))
+	http.HandleFunc("/pelanggan/level", corsMiddleware(authMiddleware(requireRoles(getPelangganLevel, RolePelanggan))))
+	http.HandleFunc("/pesan/quote", corsMiddleware(optionalAuthMiddleware(getPriceQuote)))
+	http.HandleFunc("/pesan", corsMiddleware(authMiddleware(requireRoles(createPesanan, RolePelanggan))))
+
+	http.HandleFunc("/mypay/balance", corsMiddleware(authMiddleware(requireRoles(getMyPayBalance, RolePelanggan, RolePekerja))))
+	http.HandleFunc("/mypay/history", corsMiddleware(authMiddleware(requireRoles(getMyPayHistory, RolePelanggan, RolePekerja))))
+	http.HandleFunc("/mypay/topup", corsMiddleware(authMiddleware(requireRoles(handleTopUp, RolePelanggan, RolePekerja))))
+	http.HandleFunc("/mypay/transfer", corsMiddleware(authMiddleware(requireRoles(handleTransfer, RolePelanggan, RolePekerja))))
+	http.HandleFunc("/mypay/withdraw", corsMiddleware(authMiddleware(requireRoles(handleWithdraw, RolePekerja))))
 	http.HandleFunc("/mypay/get-category-id", corsMiddleware(GetCategoryIdByName))
 
-	http.HandleFunc("/mypay/getPesananJasa", corsMiddleware(getPesananJasa))
+	http.HandleFunc("/mypay/getPesananJasa", corsMiddleware(authMiddleware(requireRoles(getPesananJasa, RolePelanggan))))
 	http.HandleFunc("/mypay/getStatusIdByName", corsMiddleware(GetStatusIdByName))
-	http.HandleFunc("/mypay/processPayment", corsMiddleware(ProcessPayment))
+	http.HandleFunc("/mypay/processPayment", corsMiddleware(authMiddleware(requireRoles(ProcessPayment, RolePelanggan))))
 	// http.HandleFunc("/mypay/transaction", corsMiddleware(handleMyPayTransaction))
-	http.HandleFunc("/pekerja/get-kategori-sub", corsMiddleware(getKategoriFromSub))
-
-	http.HandleFunc("/jobs/available", corsMiddleware(getAvailableJobs))
-	http.HandleFunc("/jobs/get-job", corsMiddleware(pickAJob))
-
-	http.HandleFunc("/jobs/job-pekerja-id", corsMiddleware(seePekerjaJob))
-	http.HandleFunc("/jobs/job-pekerja-update", corsMiddleware(updatePekerjaJob))
+	http.HandleFunc("/pekerja/photo", corsMiddleware(authMiddleware(requireRoles(uploadPekerjaPhoto, RolePekerja))))
+	http.Handle("/uploads/", uploadsHandler())
+	http.HandleFunc("/pekerja/profile", corsMiddleware(getPekerjaProfile))
+	http.HandleFunc("/pekerja/availability", corsMiddleware(authMiddleware(requireRoles(getPekerjaAvailability, RolePekerja))))
+	http.HandleFunc("/pekerja/availability/hours", corsMiddleware(authMiddleware(requireRoles(setPekerjaJamKerja, RolePekerja))))
+	http.HandleFunc("/pekerja/availability/timeoff/add", corsMiddleware(authMiddleware(requireRoles(addPekerjaCuti, RolePekerja))))
+	http.HandleFunc("/pekerja/availability/timeoff/remove", corsMiddleware(authMiddleware(requireRoles(removePekerjaCuti, RolePekerja))))
+	http.HandleFunc("/pekerja/rekening", corsMiddleware(authMiddleware(requireRoles(getPekerjaRekening, RolePekerja))))
+	http.HandleFunc("/pekerja/rekening/verify", corsMiddleware(authMiddleware(requireRoles(verifyPekerjaRekening, RolePekerja))))
+	http.HandleFunc("/pekerja/kategori", corsMiddleware(authMiddleware(requireRoles(getPekerjaKategori, RolePekerja))))
+	http.HandleFunc("/pekerja/kategori/add", corsMiddleware(authMiddleware(requireRoles(addPekerjaKategori, RolePekerja))))
+	http.HandleFunc("/pekerja/kategori/remove", corsMiddleware(authMiddleware(requireRoles(removePekerjaKategori, RolePekerja))))
+	http.HandleFunc("/pekerja/get-kategori-sub", corsMiddleware(authMiddleware(requireRoles(getKategoriFromSub, RolePekerja))))
+
+	http.HandleFunc("/jobs/available", corsMiddleware(authMiddleware(requireRoles(getAvailableJobs, RolePekerja))))
+	http.HandleFunc("/jobs/get-job", corsMiddleware(authMiddleware(requireRoles(pickAJob, RolePekerja))))
+
+	http.HandleFunc("/jobs/job-pekerja-id", corsMiddleware(authMiddleware(requireRoles(seePekerjaJob, RolePekerja))))
+	http.HandleFunc("/jobs/job-pekerja-update", corsMiddleware(authMiddleware(requireRoles(updatePekerjaJob, RolePekerja))))
+
+	http.HandleFunc("/admin/kategori/create", corsMiddleware(authMiddleware(requireRoles(createKategori, RoleAdmin))))
+	http.HandleFunc("/admin/kategori/update", corsMiddleware(authMiddleware(requireRoles(updateKategori, RoleAdmin))))
+	http.HandleFunc("/admin/kategori/archive", corsMiddleware(authMiddleware(requireRoles(archiveCatalogHandler(kategoriCatalog), RoleAdmin))))
+	http.HandleFunc("/admin/kategori/reorder", corsMiddleware(authMiddleware(requireRoles(reorderCatalogHandler(kategoriCatalog), RoleAdmin))))
+	http.HandleFunc("/admin/subkategori/create", corsMiddleware(authMiddleware(requireRoles(createSubkategori, RoleAdmin))))
+	http.HandleFunc("/admin/subkategori/update", corsMiddleware(authMiddleware(requireRoles(updateSubkategori, RoleAdmin))))
+	http.HandleFunc("/admin/subkategori/archive", corsMiddleware(authMiddleware(requireRoles(archiveCatalogHandler(subkategoriCatalog), RoleAdmin))))
+	http.HandleFunc("/admin/subkategori/reorder", corsMiddleware(authMiddleware(requireRoles(reorderCatalogHandler(subkategoriCatalog), RoleAdmin))))
+	http.HandleFunc("/admin/sesi/create", corsMiddleware(authMiddleware(requireRoles(createSesi, RoleAdmin))))
+	http.HandleFunc("/admin/sesi/update", corsMiddleware(authMiddleware(requireRoles(updateSesi, RoleAdmin))))
+	http.HandleFunc("/admin/sesi/archive", corsMiddleware(authMiddleware(requireRoles(archiveCatalogHandler(sesiCatalog), RoleAdmin))))
+	http.HandleFunc("/admin/sesi/reorder", corsMiddleware(authMiddleware(requireRoles(reorderCatalogHandler(sesiCatalog), RoleAdmin))))
+
+	http.HandleFunc("/admin/metrics/catalog-cache", corsMiddleware(authMiddleware(requireRoles(getCatalogCacheStats, RoleAdmin))))
 
 	// Endpoint baru untuk testimoni
-	http.HandleFunc("/createTestimoni", corsMiddleware(createTestimoniHandler))
+	http.HandleFunc("/createTestimoni", corsMiddleware(authMiddleware(requireRoles(createTestimoniHandler, RolePelanggan))))
 	http.HandleFunc("/getTestimoni", corsMiddleware(getTestimoniHandler))
-	http.HandleFunc("/deleteTestimoni", corsMiddleware(deleteTestimoniHandler))
+	http.HandleFunc("/updateTestimoni", corsMiddleware(authMiddleware(requireRoles(updateTestimoniHandler, RolePelanggan))))
+	http.HandleFunc("/deleteTestimoni", corsMiddleware(authMiddleware(requireRoles(deleteTestimoniHandler, RolePelanggan))))
 
 	// Endpoint untuk diskon & pembelian voucher
 	http.HandleFunc("/getDiskon", corsMiddleware(getDiskonHandler))
-	http.HandleFunc("/buyVoucher", corsMiddleware(buyVoucherHandler))
+	http.HandleFunc("/buyVoucher", corsMiddleware(authMiddleware(requireRoles(buyVoucherHandler, RolePelanggan))))
 
 	fmt.Println("Server is listening on port 8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
@@ -335,6 +411,41 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+func validateRegisterRequest(body RegisterRequestBody) (FieldErrors, error) {
+	errs := FieldErrors{}
+
+	if body.Role != 0 && body.Role != 1 {
+		errs.add("role", "Role harus 0 (pelanggan) atau 1 (pekerja)")
+	}
+	validateRequired(errs, "name", body.Nama, "Nama wajib diisi")
+	validateRequired(errs, "sex", body.JenisKelamin, "Jenis kelamin wajib diisi")
+	validateNoHP(errs, "number", body.NoHP)
+	if err := validatePasswordStrength(body.Pwd); err != nil {
+		errs.add("password", err.Error())
+	}
+	validateTglLahir(errs, "date", body.TglLahir)
+	validateRequired(errs, "address", body.Alamat, "Alamat wajib diisi")
+	validateRequired(errs, "otp", body.OTP, "Kode OTP wajib diisi")
+
+	if body.Role == 1 {
+		validateRequired(errs, "bank", body.NamaBank, "Nama bank wajib diisi")
+		validateNomorRekening(errs, "noRek", body.NomorRekening)
+		validateNPWP(errs, "npwp", body.NPWP)
+	}
+
+	if _, exists := errs["number"]; !exists {
+		taken, err := noHPTaken(db, body.NoHP, "")
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			errs.add("number", "Nomor HP sudah terdaftar")
+		}
+	}
+
+	return errs, nil
+}
+
 func register(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
@@ -348,72 +459,118 @@ func register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var userId string
-	err = db.QueryRow(`INSERT INTO "user" VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING Id`, uuid.New(), body.Nama, body.JenisKelamin, body.NoHP, body.Pwd, body.TglLahir, body.Alamat, 0.0).Scan(&userId)
-	if err == sql.ErrNoRows {
+	errs, err := validateRegisterRequest(body)
+	if err != nil {
 		response := &RegisterResponseBody{
 			Status:  false,
-			Message: "Invalid Credential on user",
+			Message: err.Error(),
 		}
 
+		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(response)
 		return
-	} else if err != nil {
+	}
+	if len(errs) > 0 {
+		response := &RegisterResponseBody{
+			Status:  false,
+			Message: "Data registrasi tidak valid",
+			Errors:  errs,
+		}
+
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	codeHash, err := checkOTP(db, body.NoHP, otpPurposeRegister, body.OTP)
+	if err != nil {
 		response := &RegisterResponseBody{
 			Status:  false,
 			Message: err.Error(),
+			Errors:  FieldErrors{"otp": err.Error()},
 		}
 
+		w.WriteHeader(http.StatusUnprocessableEntity)
 		json.NewEncoder(w).Encode(response)
 		return
 	}
 
-	if body.Role == 0 {
-		err = db.QueryRow(`INSERT INTO PELANGGAN VALUES ($1, $2) RETURNING Id`, userId, "Basic").Scan(&userId)
-		if err == sql.ErrNoRows {
-			response := &RegisterResponseBody{
-				Status:  false,
-				Message: "Invalid Credential on pelanggan",
-			}
+	pwdHash, err := hashPassword(body.Pwd)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
 
-			json.NewEncoder(w).Encode(response)
-			return
-		} else if err != nil {
-			response := &RegisterResponseBody{
-				Status:  false,
-				Message: err.Error(),
-			}
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
 
-			json.NewEncoder(w).Encode(response)
-			return
+	// OTP dihapus di transaksi yang sama sehingga kode tidak hangus jika
+	// pembuatan akun gagal
+	if err := deleteOTP(tx, body.NoHP, otpPurposeRegister, codeHash); err != nil {
+		response := &RegisterResponseBody{
+			Status:  false,
+			Message: err.Error(),
+			Errors:  FieldErrors{"otp": err.Error()},
 		}
+
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	userId := uuid.New().String()
+	_, err = tx.Exec(`INSERT INTO "user" (Id, Nama, JenisKelamin, NoHP, Pwd, TglLahir, Alamat, SaldoMyPay, IsVerified)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, 0, TRUE)`,
+		userId, body.Nama, body.JenisKelamin, body.NoHP, pwdHash, body.TglLahir, body.Alamat)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		response := &RegisterResponseBody{
+			Status:  false,
+			Message: "Data registrasi tidak valid",
+			Errors:  FieldErrors{"number": "Nomor HP sudah terdaftar"},
+		}
+
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		response := &RegisterResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		}
+
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Level, Rating dan JmlPsnananSelesai selalu ditentukan server
+	if body.Role == 0 {
+		_, err = tx.Exec(`INSERT INTO PELANGGAN (Id, Level) VALUES ($1, 'Basic')`, userId)
 	} else {
-		err = db.QueryRow(`INSERT INTO PEKERJA VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING Id`,
+		// LinkFoto diisi setelah pekerja mengunggah foto lewat /pekerja/photo
+		_, err = tx.Exec(`INSERT INTO PEKERJA (Id, NamaBank, NomorRekening, NPWP, LinkFoto, Rating, JmlPsnananSelesai)
+		VALUES ($1, $2, $3, $4, '', 0, 0)`,
 			userId,
 			body.NamaBank,
 			body.NomorRekening,
-			body.NPWP,
-			body.LinkFoto,
-			body.Rating,
-			body.JmlPsnananSelesai).Scan(&userId)
-
-		if err == sql.ErrNoRows {
-			response := &RegisterResponseBody{
-				Status:  false,
-				Message: "Invalid Credential on pekerja",
-			}
+			body.NPWP)
+	}
+	if err != nil {
+		response := &RegisterResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		}
 
-			json.NewEncoder(w).Encode(response)
-			return
-		} else if err != nil {
-			response := &RegisterResponseBody{
-				Status:  false,
-				Message: err.Error(),
-			}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
 
-			json.NewEncoder(w).Encode(response)
-			return
-		}
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
 	}
 
 	response := &RegisterResponseBody{
@@ -424,37 +581,81 @@ func register(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// Kolom yang boleh diubah lewat /updateUser, dipetakan dari nama field JSON.
+// Field di luar daftar ini, termasuk password, selalu ditolak.
+var (
+	userPatchColumns = map[string]string{
+		"name":    "Nama",
+		"sex":     "JenisKelamin",
+		"number":  "NoHP",
+		"date":    "TglLahir",
+		"address": "Alamat",
+	}
+	pekerjaPatchColumns = map[string]string{
+		"bank":  "NamaBank",
+		"noRek": "NomorRekening",
+		"npwp":  "NPWP",
+	}
+)
+
+// decodeUserPatchValue membaca dan memvalidasi satu nilai dari body merge
+// patch. Nilai nil dikembalikan jika field tidak valid.
+func decodeUserPatchValue(errs FieldErrors, field string, raw json.RawMessage) interface{} {
+	if string(raw) == "null" {
+		errs.add(field, "Field ini tidak boleh dikosongkan")
+		return nil
+	}
+
+	if field == "date" {
+		var tglLahir time.Time
+		if err := json.Unmarshal(raw, &tglLahir); err != nil {
+			errs.add(field, "Format tanggal tidak valid")
+			return nil
+		}
+		validateTglLahir(errs, field, tglLahir)
+		return tglLahir
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		errs.add(field, "Nilai harus berupa teks")
+		return nil
+	}
+
+	switch field {
+	case "number":
+		validateNoHP(errs, field, value)
+	case "noRek":
+		validateNomorRekening(errs, field, value)
+	case "npwp":
+		validateNPWP(errs, field, value)
+	default:
+		validateRequired(errs, field, value, "Field ini wajib diisi")
+	}
+	return value
+}
+
+// updateUser menerapkan body sebagai JSON Merge Patch (RFC 7396): hanya field
+// yang dikirim yang diubah, dalam satu transaksi untuk "user" dan PEKERJA.
 func updateUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPatch {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var body UpdateUserRequestBody
-	err := json.NewDecoder(r.Body).Decode(&body)
+	var patch map[string]json.RawMessage
+	err := json.NewDecoder(r.Body).Decode(&patch)
 	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	var oldValue UpdateUserRequestBody
-	err = db.QueryRow(`SELECT Nama, JenisKelamin, NoHP, TglLahir, Alamat FROM "user" WHERE Id = $1`, body.User).
-		Scan(
-			&oldValue.Nama,
-			&oldValue.JenisKelamin,
-			&oldValue.NoHP,
-			&oldValue.TglLahir,
-			&oldValue.Alamat)
+	userID := currentUserID(r)
+	role := currentRole(r)
 
-	if err == sql.ErrNoRows {
-		response := &UpdateUserResponseBody{
-			Status:  false,
-			Message: "Invalid Credential on user",
-		}
-
-		json.NewEncoder(w).Encode(response)
-		return
-	} else if err != nil {
+	var currentNoHP, currentNama string
+	err = db.QueryRow(`SELECT NoHP, Nama FROM "user" WHERE Id = $1`, userID).Scan(&currentNoHP, &currentNama)
+	if err != nil {
 		response := &UpdateUserResponseBody{
 			Status:  false,
 			Message: err.Error(),
@@ -464,190 +665,208 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if body.Nama != oldValue.Nama {
-		oldValue.Nama = body.Nama
-	}
+	var currentBank, currentNoRek string
+	if role == RolePekerja {
+		err = db.QueryRow(`SELECT NamaBank, NomorRekening FROM PEKERJA WHERE Id = $1`, userID).Scan(&currentBank, &currentNoRek)
+		if err != nil {
+			response := &UpdateUserResponseBody{
+				Status:  false,
+				Message: err.Error(),
+			}
 
-	if body.JenisKelamin != oldValue.JenisKelamin {
-		oldValue.JenisKelamin = body.JenisKelamin
+			json.NewEncoder(w).Encode(response)
+			return
+		}
 	}
 
-	if body.TglLahir != oldValue.TglLahir {
-		oldValue.TglLahir = body.TglLahir
+	fields := make([]string, 0, len(patch))
+	for field := range patch {
+		fields = append(fields, field)
 	}
+	sort.Strings(fields)
+
+	errs := FieldErrors{}
+	var userSet, pekerjaSet []string
+	var userArgs, pekerjaArgs []interface{}
+	newNoHP := ""
+	rekeningChanged := false
+	for _, field := range fields {
+		// Identitas dan role selalu diambil dari access token
+		if field == "user" || field == "role" {
+			continue
+		}
+
+		userColumn, isUserField := userPatchColumns[field]
+		pekerjaColumn, isPekerjaField := pekerjaPatchColumns[field]
+		if !isUserField && !isPekerjaField {
+			errs.add(field, "Field ini tidak dapat diubah")
+			continue
+		}
+		if isPekerjaField && role != RolePekerja {
+			errs.add(field, "Field ini hanya untuk pekerja")
+			continue
+		}
+
+		value := decodeUserPatchValue(errs, field, patch[field])
+		if value == nil {
+			continue
+		}
+
+		// Rekening diverifikasi atas nama pemilik, jadi perubahan nama juga
+		// membatalkan verifikasi rekening pekerja
+		switch field {
+		case "name":
+			rekeningChanged = rekeningChanged || (role == RolePekerja && value != currentNama)
+		case "bank":
+			rekeningChanged = rekeningChanged || value != currentBank
+		case "noRek":
+			rekeningChanged = rekeningChanged || value != currentNoRek
+		}
 
-	if body.Alamat != oldValue.Alamat {
-		oldValue.Alamat = body.Alamat
+		if isUserField {
+			if field == "number" {
+				// Nomor baru disimpan terpisah sampai OTP-nya dikonfirmasi
+				if value != currentNoHP {
+					newNoHP = value.(string)
+				}
+				continue
+			}
+			userArgs = append(userArgs, value)
+			userSet = append(userSet, fmt.Sprintf("%s = $%d", userColumn, len(userArgs)))
+		} else {
+			pekerjaArgs = append(pekerjaArgs, value)
+			pekerjaSet = append(pekerjaSet, fmt.Sprintf("%s = $%d", pekerjaColumn, len(pekerjaArgs)))
+		}
 	}
 
-	var current_user_id string
-	err = db.QueryRow(`UPDATE "user" SET Nama = $1, JenisKelamin = $2, TglLahir = $3, Alamat = $4 WHERE Id = $5 Returning Id`,
-		oldValue.Nama,
-		oldValue.JenisKelamin,
-		oldValue.TglLahir,
-		oldValue.Alamat,
-		body.User).Scan(&current_user_id)
+	noHPChanged := newNoHP != ""
+	if noHPChanged {
+		if _, invalid := errs["number"]; !invalid {
+			taken, err := noHPTaken(db, newNoHP, userID)
+			if err != nil {
+				response := &UpdateUserResponseBody{
+					Status:  false,
+					Message: err.Error(),
+				}
 
-	if body.NoHP != oldValue.NoHP {
-		err = db.QueryRow(`UPDATE "user" SET NoHP = $1 WHERE Id = $2 Returning Id`,
-			body.NoHP,
-			body.User).Scan(&current_user_id)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(response)
+				return
+			}
+			if taken {
+				errs.add("number", "Nomor HP sudah terdaftar")
+			}
+		}
 	}
 
-	if err == sql.ErrNoRows {
+	if len(errs) > 0 {
 		response := &UpdateUserResponseBody{
 			Status:  false,
-			Message: "Invalid update on user",
+			Message: "Data profil tidak valid",
+			Errors:  errs,
 		}
 
+		w.WriteHeader(http.StatusUnprocessableEntity)
 		json.NewEncoder(w).Encode(response)
 		return
-	} else if err != nil {
-		response := &UpdateUserResponseBody{
-			Status:  false,
-			Message: err.Error() + " User",
-		}
+	}
 
-		json.NewEncoder(w).Encode(response)
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	if body.Role == 1 {
-		err = db.QueryRow(`SELECT NPWP, LinkFoto, NamaBank, NomorRekening FROM PEKERJA WHERE Id = $1`, body.User).
-			Scan(
-				&oldValue.NPWP,
-				&oldValue.LinkFoto,
-				&oldValue.NamaBank,
-				&oldValue.NomorRekening)
-		if err == sql.ErrNoRows {
+	if noHPChanged {
+		// Nomor baru baru dipakai setelah dikonfirmasi lewat /updateUser/number/verify
+		userArgs = append(userArgs, newNoHP)
+		userSet = append(userSet, fmt.Sprintf("NoHPBaru = $%d", len(userArgs)))
+	}
+
+	if len(userSet) > 0 {
+		userArgs = append(userArgs, userID)
+		_, err = tx.Exec(fmt.Sprintf(`UPDATE "user" SET %s WHERE Id = $%d`, strings.Join(userSet, ", "), len(userArgs)), userArgs...)
+		if err != nil {
 			response := &UpdateUserResponseBody{
 				Status:  false,
-				Message: "Invalid Credential on pekerja",
+				Message: err.Error() + " User",
 			}
 
 			json.NewEncoder(w).Encode(response)
 			return
-		} else if err != nil {
+		}
+	}
+
+	if len(pekerjaSet) > 0 {
+		pekerjaArgs = append(pekerjaArgs, userID)
+		_, err = tx.Exec(fmt.Sprintf(`UPDATE PEKERJA SET %s WHERE Id = $%d`, strings.Join(pekerjaSet, ", "), len(pekerjaArgs)), pekerjaArgs...)
+		if err != nil {
 			response := &UpdateUserResponseBody{
 				Status:  false,
-				Message: err.Error() + " Update",
+				Message: err.Error() + " Pekerja",
 			}
 
 			json.NewEncoder(w).Encode(response)
 			return
 		}
+	}
 
-		if body.NPWP != oldValue.NPWP {
-			oldValue.NPWP = body.NPWP
-		}
-
-		if body.LinkFoto != oldValue.LinkFoto {
-			oldValue.LinkFoto = body.LinkFoto
-		}
-
-		err = db.QueryRow(`UPDATE PEKERJA SET 
-        NPWP = $1, 
-        LinkFoto = $2 
-        WHERE Id = $3 Returning Id`,
-			oldValue.NPWP,
-			oldValue.LinkFoto,
-			body.User).Scan(&current_user_id)
-		if err == sql.ErrNoRows {
+	if rekeningChanged {
+		if err := resetRekeningVerification(tx, userID); err != nil {
 			response := &UpdateUserResponseBody{
 				Status:  false,
-				Message: "Invalid Credential on pekerja",
+				Message: err.Error(),
 			}
 
 			json.NewEncoder(w).Encode(response)
 			return
-		} else if err != nil {
+		}
+	}
+
+	if noHPChanged {
+		_, err = issueOTP(db, smsSender, newNoHP, otpPurposeNoHPBaru)
+		if err != nil {
 			response := &UpdateUserResponseBody{
 				Status:  false,
-				Message: err.Error() + " Pekerja",
+				Message: err.Error(),
 			}
 
 			json.NewEncoder(w).Encode(response)
 			return
 		}
+	}
 
-		if body.NomorRekening != oldValue.NomorRekening && body.NamaBank != oldValue.NamaBank {
-			err = db.QueryRow(`UPDATE PEKERJA SET 
-			NamaBank = $1, 
-			NomorRekening = $2 
-			WHERE Id = $3 Returning Id`,
-				body.NamaBank,
-				body.NomorRekening,
-				body.User).Scan(&current_user_id)
-			if err == sql.ErrNoRows {
-				response := &UpdateUserResponseBody{
-					Status:  false,
-					Message: "Invalid Credential on pekerja",
-				}
-
-				json.NewEncoder(w).Encode(response)
-				return
-			} else if err != nil {
-				response := &UpdateUserResponseBody{
-					Status:  false,
-					Message: err.Error() + " Update",
-				}
-
-				json.NewEncoder(w).Encode(response)
-				return
-			}
-		} else if body.NamaBank != oldValue.NamaBank {
-			err = db.QueryRow(`UPDATE PEKERJA SET 
-			NamaBank = $1
-			WHERE Id = $2 Returning Id`,
-				body.NamaBank,
-				body.User).Scan(&current_user_id)
-			if err == sql.ErrNoRows {
-				response := &UpdateUserResponseBody{
-					Status:  false,
-					Message: "Invalid Credential on pekerja",
-				}
-
-				json.NewEncoder(w).Encode(response)
-				return
-			} else if err != nil {
-				response := &UpdateUserResponseBody{
-					Status:  false,
-					Message: err.Error() + " Bank Name",
-				}
-
-				json.NewEncoder(w).Encode(response)
-				return
-			}
-		} else if body.NomorRekening != oldValue.NomorRekening {
-			err = db.QueryRow(`UPDATE PEKERJA SET 
-			NomorRekening = $1
-			WHERE Id = $2 Returning Id`,
-				body.NomorRekening,
-				body.User).Scan(&current_user_id)
-			if err == sql.ErrNoRows {
-				response := &UpdateUserResponseBody{
-					Status:  false,
-					Message: "Invalid Credential on pekerja",
-				}
-
-				json.NewEncoder(w).Encode(response)
-				return
-			} else if err != nil {
-				response := &UpdateUserResponseBody{
-					Status:  false,
-					Message: err.Error() + " Rekening",
-				}
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+	if role == RolePekerja {
+		catalogResponses.invalidate()
+	}
 
-				json.NewEncoder(w).Encode(response)
-				return
-			}
+	profile, err := loadUserProfile(db, userID, role)
+	if err != nil {
+		response := &UpdateUserResponseBody{
+			Status:  false,
+			Message: err.Error(),
 		}
 
+		json.NewEncoder(w).Encode(response)
+		return
 	}
+	profile.Status = true
+	profile.Message = "Berhasil mendapatkan data"
 
-	response := &RegisterResponseBody{
+	message := fmt.Sprintf("User dengan id %s berhasil di update", userID)
+	if noHPChanged {
+		message += ", masukkan kode OTP yang dikirim ke nomor HP baru untuk menggantinya"
+	}
+
+	response := &UpdateUserResponseBody{
 		Status:  true,
-		Message: fmt.Sprint("User dengan id $s berhasil di update", current_user_id),
+		Message: message,
+		Profile: profile,
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -666,80 +885,107 @@ func checkLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	retryAt, err := loginRetryAt(db, body.NoHP, clientIP(r))
+	if err != nil {
+		response := &LoginResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		}
+
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if !retryAt.IsZero() {
+		writeLoginThrottled(w, retryAt)
+		return
+	}
+
 	var userID string
 	var name string
+	var storedPwd string
+	var verified bool
+	var deactivated bool
 	var role int
 
-	err = db.QueryRow(`SELECT Id, Nama FROM "user" WHERE NoHP = $1 AND Pwd = $2`, body.NoHP, body.Pwd).Scan(&userID, &name)
+	err = db.QueryRow(`SELECT Id, Nama, Pwd, IsVerified, DeactivatedAt IS NOT NULL FROM "user" WHERE NoHP = $1`, body.NoHP).
+		Scan(&userID, &name, &storedPwd, &verified, &deactivated)
 	if err == sql.ErrNoRows {
+		verifyPassword(dummyPasswordHash, body.Pwd)
+		loginFailureResponse(w, r, body.NoHP, "Invalid Credential")
+		return
+	} else if err != nil {
 		response := &LoginResponseBody{
 			Status:  false,
 			UserId:  userID,
 			Name:    name,
 			Role:    role,
-			Message: "Invalid Credential",
+			Message: err.Error(),
 		}
 
 		json.NewEncoder(w).Encode(response)
 		return
-	} else if err != nil {
+	}
+
+	ok, needsUpgrade := verifyPassword(storedPwd, body.Pwd)
+	if !ok {
+		loginFailureResponse(w, r, body.NoHP, "Invalid Credential")
+		return
+	}
+
+	if err := clearLoginFailures(db, body.NoHP); err != nil {
+		log.Printf("Error clearing login failures for %s: %v", body.NoHP, err)
+	}
+
+	if needsUpgrade {
+		if err := upgradePasswordHash(db, userID, body.Pwd); err != nil {
+			log.Printf("Error upgrading password hash for %s: %v", userID, err)
+		}
+	}
+
+	if deactivated {
 		response := &LoginResponseBody{
 			Status:  false,
-			UserId:  userID,
-			Name:    name,
-			Role:    role,
-			Message: err.Error(),
+			Message: "Akun sudah dinonaktifkan",
 		}
 
 		json.NewEncoder(w).Encode(response)
 		return
 	}
 
-	db.QueryRow(`SELECT 1 FROM PELANGGAN WHERE Id = $1`, userID).Scan(&role)
-	response := &LoginResponseBody{
-		Status:  true,
-		UserId:  userID,
-		Name:    name,
-		Role:    role,
-		Message: "Success",
-	}
-
-	json.NewEncoder(w).Encode(response)
-}
+	if !verified {
+		response := &LoginResponseBody{
+			Status:  false,
+			Message: "Nomor HP belum diverifikasi",
+		}
 
-func getUser(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPatch {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(response)
 		return
 	}
 
-	var body GetUserRequestBody
-	err := json.NewDecoder(r.Body).Decode(&body)
+	roleName, err := resolveRole(db, userID)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		response := &LoginResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		}
+
+		json.NewEncoder(w).Encode(response)
 		return
 	}
 
-	var response GetUserResponseBody
-	err = db.QueryRow(`SELECT Nama, JenisKelamin, NoHP, Pwd, TglLahir, Alamat, SaldoMyPay FROM "user" WHERE Id = $1`, body.User).Scan(
-		&response.Nama,
-		&response.JenisKelamin,
-		&response.NoHP,
-		&response.Pwd,
-		&response.TglLahir,
-		&response.Alamat,
-		&response.SaldoMyPay)
-
-	if err == sql.ErrNoRows {
-		response := &GetUserResponseBody{
+	sessionID, refreshToken, err := createSession(db, userID, r)
+	if err != nil {
+		response := &LoginResponseBody{
 			Status:  false,
-			Message: "Invalid Credential",
+			Message: err.Error(),
 		}
 
 		json.NewEncoder(w).Encode(response)
 		return
-	} else if err != nil {
-		response := &GetUserResponseBody{
+	}
+
+	token, expiresAt, err := issueAccessToken(userID, sessionID)
+	if err != nil {
+		response := &LoginResponseBody{
 			Status:  false,
 			Message: err.Error(),
 		}
@@ -748,108 +994,178 @@ func getUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response.Status = true
-	response.Message = "Berhasil mendapatkan data"
+	response := &LoginResponseBody{
+		Status:       true,
+		UserId:       userID,
+		Name:         name,
+		Role:         legacyLoginRole(roleName),
+		Message:      "Success",
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    &expiresAt,
+		RoleName:     roleName,
+	}
 
-	if body.Role == 0 {
-		db.QueryRow(`SELECT Level FROM PELANGGAN WHERE Id = $1`, body.User).Scan(&response.Level)
-		json.NewEncoder(w).Encode(response)
-	} else {
-		db.QueryRow(`SELECT NamaBank, NomorRekening, NPWP, LinkFoto, Rating, JmlPsnananSelesai FROM PEKERJA WHERE Id = $1`, body.User).Scan(
+	json.NewEncoder(w).Encode(response)
+}
+
+// loadUserProfile membaca profil lengkap user sesuai role-nya. Mengembalikan
+// sql.ErrNoRows jika user tidak ditemukan.
+func loadUserProfile(db *sql.DB, userID string, role Role) (*GetUserResponseBody, error) {
+	response := &GetUserResponseBody{User: userID}
+	err := db.QueryRow(`SELECT Nama, JenisKelamin, NoHP, TglLahir, Alamat, SaldoMyPay FROM "user" WHERE Id = $1`, userID).Scan(
+		&response.Nama,
+		&response.JenisKelamin,
+		&response.NoHP,
+		&response.TglLahir,
+		&response.Alamat,
+		&response.SaldoMyPay)
+	if err != nil {
+		return nil, err
+	}
+
+	response.AlamatDefault, err = loadDefaultAlamat(db, userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	switch role {
+	case RolePelanggan:
+		response.Role = 0
+		db.QueryRow(`SELECT Level FROM PELANGGAN WHERE Id = $1`, userID).Scan(&response.Level)
+	case RolePekerja:
+		response.Role = 1
+		db.QueryRow(`SELECT NamaBank, NomorRekening, NPWP, LinkFoto, COALESCE(LinkFotoThumbnail, ''), Rating, JmlPsnananSelesai FROM PEKERJA WHERE Id = $1`, userID).Scan(
 			&response.NamaBank,
 			&response.NomorRekening,
 			&response.NPWP,
 			&response.LinkFoto,
+			&response.LinkFotoThumbnail,
 			&response.Rating,
 			&response.JmlPsnananSelesai)
 
 		rows, err := db.Query(`SELECT NamaKategori FROM KATEGORI_JASA LEFT JOIN PEKERJA_KATEGORI_JASA 
-		ON Id = KategoriJasaId WHERE PekerjaId = $1`, body.User)
-
-		var kategoriList []string
+		ON Id = KategoriJasaId WHERE PekerjaId = $1`, userID)
 		if err != nil {
-			log.Println("Error executing query:", err)
-			return
+			return nil, err
 		}
 		defer rows.Close()
 
+		var kategoriList []string
 		for rows.Next() {
 			var namaKategori string
 			if err := rows.Scan(&namaKategori); err != nil {
-				log.Println("Error scanning row:", err)
-				return
+				return nil, err
 			}
 			kategoriList = append(kategoriList, namaKategori)
 		}
-		response.PekerjaKategoriJasa = kategoriList
+		response.PekerjaKategoriJasa = kategoriList
+	}
+
+	return response, nil
+}
+
+func getUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body GetUserRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	body.User = currentUserID(r)
+
+	response, err := loadUserProfile(db, body.User, currentRole(r))
+	if err == sql.ErrNoRows {
+		response := &GetUserResponseBody{
+			Status:  false,
+			Message: "Invalid Credential",
+		}
+
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		response := &GetUserResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		}
 
 		json.NewEncoder(w).Encode(response)
-	}
-}
-
-// Get Homepage (all categories and subcategories)
-func getHomepage(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query(`
-		SELECT k.id, k.nama, s.id, s.nama
-		FROM kategori_jasa k
-		LEFT JOIN subkategori_jasa s ON k.id = s.id_kategori`)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var data []map[string]interface{}
-	for rows.Next() {
-		var kategoriID, subkategoriID int
-		var kategoriNama, subkategoriNama string
-		if err := rows.Scan(&kategoriID, &kategoriNama, &subkategoriID, &subkategoriNama); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		data = append(data, map[string]interface{}{
-			"kategori_id":      kategoriID,
-			"kategori_nama":    kategoriNama,
-			"subkategori_id":   subkategoriID,
-			"subkategori_nama": subkategoriNama,
-		})
-	}
-	json.NewEncoder(w).Encode(data)
+	response.Status = true
+	response.Message = "Berhasil mendapatkan data"
+	json.NewEncoder(w).Encode(response)
 }
 
 // Get Subkategori and Sessions
 func getSubkategori(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
-	rows, err := db.Query(`
-		SELECT s.id, s.nama, s.deskripsi, sesi.id, sesi.nama_sesi, sesi.harga
-		FROM subkategori_jasa s
-		LEFT JOIN sesi_layanan sesi ON s.id = sesi.id_subkategori
-		WHERE s.id = $1`, id)
+	err := serveCatalogJSON(w, r, "subkategori:"+id, func() ([]byte, error) {
+		return loadSubkategoriJSON(id)
+	})
+	// Id yang tidak ada tetap dijawab null seperti sebelumnya, tetapi tidak
+	// disimpan di cache agar id acak tidak memenuhi memori
+	if err == sql.ErrNoRows {
+		json.NewEncoder(w).Encode(nil)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	}
+}
+
+func loadSubkategoriJSON(id string) ([]byte, error) {
+	rows, err := db.Query(`
+		SELECT s.Id, s.NamaSubkategori, COALESCE(s.Deskripsi, ''), sesi.id, sesi.nama_sesi, sesi.harga
+		FROM SUBKATEGORI_JASA s
+		JOIN KATEGORI_JASA k ON k.Id = s.KategoriJasaId
+		LEFT JOIN sesi_layanan sesi ON s.Id = sesi.id_subkategori AND sesi.archived_at IS NULL
+		WHERE s.Id::text = $1
+		AND k.ArchivedAt IS NULL AND s.ArchivedAt IS NULL
+		ORDER BY sesi.urutan, sesi.harga, sesi.id`, id)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
+	// Subkategori tanpa sesi tetap dikembalikan dengan field sesi bernilai null
 	var data []map[string]interface{}
 	for rows.Next() {
-		var subID, sesiID int
-		var subNama, subDeskripsi, sesiNama string
-		var harga float64
+		var subID, subNama, subDeskripsi string
+		var sesiID sql.NullInt64
+		var sesiNama sql.NullString
+		var harga sql.NullFloat64
 		if err := rows.Scan(&subID, &subNama, &subDeskripsi, &sesiID, &sesiNama, &harga); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
-		data = append(data, map[string]interface{}{
+		item := map[string]interface{}{
 			"subkategori_id":        subID,
 			"subkategori_nama":      subNama,
 			"subkategori_deskripsi": subDeskripsi,
-			"sesi_id":               sesiID,
-			"sesi_nama":             sesiNama,
-			"harga":                 harga,
-		})
+			"sesi_id":               nil,
+			"sesi_nama":             nil,
+			"harga":                 nil,
+		}
+		if sesiID.Valid {
+			item["sesi_id"] = sesiID.Int64
+			item["sesi_nama"] = sesiNama.String
+			item["harga"] = harga.Float64
+		}
+		data = append(data, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	json.NewEncoder(w).Encode(data)
+	if len(data) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return json.Marshal(data)
 }
 
 // Create Order
@@ -863,24 +1179,116 @@ func createPesanan(w http.ResponseWriter, r *http.Request) {
 		UserID           string  `json:"user_id"`
 		SesiID           int     `json:"sesi_id"`
 		Tanggal          string  `json:"tanggal"`
-		Diskon           float64 `json:"diskon"`
+		KodeDiskon       string  `json:"kode_diskon"`
 		MetodePembayaran string  `json:"metode_pembayaran"`
 		Total            float64 `json:"total"`
+		AlamatID         string  `json:"alamat_id"`
+		Jam              string  `json:"jam"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, "Invalid body", http.StatusBadRequest)
 		return
 	}
+	body.UserID = currentUserID(r)
+
+	// Total dihitung ulang di server, nilai total dari client diabaikan
+	errs := FieldErrors{}
+	waktu := parsePricingWaktu(errs, body.Tanggal, body.Jam)
+	location, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	validateTanggalPesanan(errs, waktu, time.Now().In(location))
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(&PriceQuoteResponseBody{
+			Status:  false,
+			Message: "Data pesanan tidak valid",
+			Errors:  errs,
+		})
+		return
+	}
+	quote, err := quotePrice(db, body.SesiID, waktu)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Sesi tidak ditemukan", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body.Total = quote.Total
+
+	// Tanpa alamat_id pesanan memakai alamat default, jika ada
+	if body.AlamatID != "" {
+		if _, err := uuid.Parse(body.AlamatID); err != nil {
+			http.Error(w, "Invalid alamat_id format", http.StatusBadRequest)
+			return
+		}
+	}
+	alamat, err := chooseAlamatPesanan(db, body.UserID, body.AlamatID)
+	if err == errAlamatTidakDitemukan {
+		http.Error(w, "Alamat tidak ditemukan", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var alamatID sql.NullString
+	if alamat != nil {
+		alamatID = sql.NullString{String: alamat.Id, Valid: true}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Diskon hanya berasal dari kode voucher atau promo yang divalidasi di
+	// server, lalu ikut mengurangi total
+	var potongan float64
+	if body.KodeDiskon != "" {
+		diskon, err := loadDiskon(tx, body.UserID, body.KodeDiskon, waktu.Tanggal, true)
+		if err == errDiskonTidakBerlaku || err == errDiskonMinPesanan {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(&PriceQuoteResponseBody{
+				Status:  false,
+				Message: "Data pesanan tidak valid",
+				Errors:  FieldErrors{"kode_diskon": err.Error()},
+			})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := useVoucher(tx, diskon); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, total := applyDiskon(quote.Rincian, body.Total, *diskon)
+		potongan = body.Total - total
+		body.Total = total
+	}
 
-	_, err := db.Exec(`
-		INSERT INTO pesanan (id_user, id_sesi, tanggal, diskon, metode_pembayaran, total, status)
-		VALUES ($1, $2, $3, $4, $5, $6, 'Menunggu Pembayaran')`,
-		body.UserID, body.SesiID, body.Tanggal, body.Diskon, body.MetodePembayaran, body.Total)
+	_, err = tx.Exec(`
+		INSERT INTO pesanan (id_user, id_sesi, tanggal, diskon, metode_pembayaran, total, status, id_alamat)
+		VALUES ($1, $2, $3, $4, $5, $6, 'Menunggu Pembayaran', $7)`,
+		body.UserID, body.SesiID, body.Tanggal, potongan, body.MetodePembayaran, body.Total, alamatID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	w.Write([]byte("Order created"))
 }
@@ -914,6 +1322,7 @@ func getMyPayBalance(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	request.User = currentUserID(r)
 
 	if request.User == "" {
 		http.Error(w, "Missing user ID in request body", http.StatusBadRequest)
@@ -957,6 +1366,7 @@ func getMyPayHistory(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to parse request body", http.StatusBadRequest)
 		return
 	}
+	requestBody.User = currentUserID(r)
 
 	// Check if user ID is present in the request body
 	if requestBody.User == "" {
@@ -1010,6 +1420,11 @@ func handleTopUp(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	transaction.UserID = currentUserID(r)
+
+	if !checkMyPayCredit(w, transaction.Nominal, transaction.KategoriID) {
+		return
+	}
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -1069,6 +1484,11 @@ func handleTransfer(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	transaction.UserID = currentUserID(r)
+
+	if !checkMyPayCredit(w, transaction.Nominal, transaction.KategoriID) {
+		return
+	}
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -1170,6 +1590,7 @@ func getPesananJasa(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	body.User = currentUserID(r)
 
 	var response GetPesananJasaResponseBody
 
@@ -1270,12 +1691,14 @@ func ProcessPayment(w http.ResponseWriter, r *http.Request) {
 	var requestBody struct {
 		UserId    string `json:"userId"`    // UUID format
 		ServiceId string `json:"serviceId"` // UUID format
+		AlamatId  string `json:"alamatId"`  // Opsional, default alamat default pelanggan
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	requestBody.UserId = currentUserID(r)
 
 	// Validate UUID format for UserId and ServiceId
 	if _, err := uuid.Parse(requestBody.UserId); err != nil {
@@ -1288,9 +1711,35 @@ func ProcessPayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Pesanan yang dibayar diteruskan ke pekerja, sehingga alamat pilihan
+	// pelanggan beserta koordinatnya disalin sekarang. Salinan koordinat
+	// menjaga lokasi kerja walaupun alamat diubah atau dihapus kemudian.
+	if requestBody.AlamatId != "" {
+		if _, err := uuid.Parse(requestBody.AlamatId); err != nil {
+			http.Error(w, "Invalid AlamatId format", http.StatusBadRequest)
+			return
+		}
+	}
+	alamat, err := chooseAlamatPesanan(db, requestBody.UserId, requestBody.AlamatId)
+	if err == errAlamatTidakDitemukan {
+		http.Error(w, "Alamat tidak ditemukan", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch address", http.StatusInternalServerError)
+		return
+	}
+	var alamatID sql.NullString
+	var latitude, longitude sql.NullFloat64
+	if alamat != nil {
+		alamatID = sql.NullString{String: alamat.Id, Valid: true}
+		latitude = sql.NullFloat64{Float64: alamat.Latitude, Valid: true}
+		longitude = sql.NullFloat64{Float64: alamat.Longitude, Valid: true}
+	}
+
 	// Fetch service price
 	var servicePrice float64
-	err := db.QueryRow(`SELECT TotalBiaya FROM TR_PEMESANAN_JASA WHERE Id = $1`, requestBody.ServiceId).Scan(&servicePrice)
+	err = db.QueryRow(`SELECT TotalBiaya FROM TR_PEMESANAN_JASA WHERE Id = $1`, requestBody.ServiceId).Scan(&servicePrice)
 	if err != nil {
 		http.Error(w, "Failed to fetch service price", http.StatusInternalServerError)
 		return
@@ -1331,8 +1780,9 @@ func ProcessPayment(w http.ResponseWriter, r *http.Request) {
 	// Update service status
 	_, err = db.Exec(`
 		UPDATE TR_PEMESANAN_JASA 
-		SET IdKategoriJasa = $1, IdDiskon = NULL, IdMetodeBayar = NULL 
-		WHERE Id = $2`, newStatusId, requestBody.ServiceId)
+		SET IdKategoriJasa = $1, IdDiskon = NULL, IdMetodeBayar = NULL,
+		IdAlamat = $3, Latitude = $4, Longitude = $5
+		WHERE Id = $2`, newStatusId, requestBody.ServiceId, alamatID, latitude, longitude)
 	if err != nil {
 		http.Error(w, "Failed to update service status", http.StatusInternalServerError)
 		return
@@ -1372,6 +1822,40 @@ func getAvailableJobs(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	body.UserID = currentUserID(r)
+
+	location, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		response := &GetJobsResponse{
+			Status:  false,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	now := time.Now().In(location)
+
+	calendar, err := loadWorkerCalendar(db, body.UserID, "", now)
+	if err != nil {
+		response := &GetJobsResponse{
+			Status:  false,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Alamat default pekerja menjadi titik awal untuk mengurutkan pekerjaan
+	// dari yang terdekat
+	lokasiPekerja, err := loadDefaultAlamat(db, body.UserID)
+	if err != nil && err != sql.ErrNoRows {
+		response := &GetJobsResponse{
+			Status:  false,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
 
 	var response GetJobsResponse
 	rows, err := db.Query(`SELECT tj.Id 
@@ -1420,11 +1904,13 @@ func getAvailableJobs(w http.ResponseWriter, r *http.Request) {
 			U.Nama,  
 			TJ.Sesi,
 			TJ.TotalBiaya,
-			KJ.NamaKategori
+			KJ.NamaKategori,
+			`+alamatPesananColumns+`
 			FROM TR_PEMESANAN_JASA AS TJ
 			LEFT JOIN SUBKATEGORI_JASA AS SJ ON TJ.IdKategoriJasa = SJ.Id
 			LEFT JOIN KATEGORI_JASA AS KJ ON KJ.Id = SJ.KategoriJasaId
 			LEFT JOIN "user" AS U ON U.Id = TJ.IdPelanggan
+			`+alamatPesananJoin+`
 			WHERE  
 			TJ.Id = $1
 			`, pemesanan).Scan(
@@ -1435,12 +1921,32 @@ func getAvailableJobs(w http.ResponseWriter, r *http.Request) {
 				&response_pesan.Sesi,
 				&response_pesan.Total,
 				&response_pesan.Kategori,
+				&response_pesan.Alamat,
+				&response_pesan.Latitude,
+				&response_pesan.Longitude,
 			)
 
+			// Sembunyikan pekerjaan yang tidak muat di jadwal pekerja
+			start, end := jobSchedule(response_pesan.TanggalPesan, now, response_pesan.Sesi)
+			if calendar.conflict(start, end) != "" {
+				continue
+			}
+
+			if lokasiPekerja != nil && response_pesan.Latitude != nil && response_pesan.Longitude != nil {
+				jarak := jarakKm(lokasiPekerja.Latitude, lokasiPekerja.Longitude, *response_pesan.Latitude, *response_pesan.Longitude)
+				response_pesan.Jarak = &jarak
+			}
+
 			pesananList = append(pesananList, response_pesan)
 		}
 	}
 
+	// Pekerjaan terdekat ditampilkan lebih dulu, pesanan tanpa koordinat di akhir
+	sort.SliceStable(pesananList, func(i, j int) bool {
+		a, b := pesananList[i].Jarak, pesananList[j].Jarak
+		return a != nil && (b == nil || *a < *b)
+	})
+
 	response.Status = true
 	response.Message = "Berhasil mengambil data"
 	response.Pesanan = pesananList
@@ -1459,9 +1965,11 @@ func pickAJob(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	body.UserID = currentUserID(r)
 
+	var tglPemesanan time.Time
 	var sesi int
-	err = db.QueryRow(`SELECT Sesi FROM TR_PEMESANAN_JASA WHERE Id = $1`, body.TRID).Scan(&sesi)
+	err = db.QueryRow(`SELECT TglPemesanan, Sesi FROM TR_PEMESANAN_JASA WHERE Id = $1`, body.TRID).Scan(&tglPemesanan, &sesi)
 	if err == sql.ErrNoRows {
 		response := &PickJobResponse{
 			Status:  false,
@@ -1492,20 +2000,69 @@ func pickAJob(w http.ResponseWriter, r *http.Request) {
 	}
 	currentTime := time.Now().In(location)
 
-	date := currentTime.Format("2006-01-02")
-	time := currentTime.AddDate(0, 0, sesi).Format("2006-01-02 15:04:05")
+	tx, err := db.Begin()
+	if err != nil {
+		response := &PickJobResponse{
+			Status:  false,
+			Message: err.Error(),
+		}
+
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	defer tx.Rollback()
+
+	// Kunci baris pekerja agar dua klaim bersamaan tidak sama-sama lolos cek jadwal
+	var locked string
+	err = tx.QueryRow(`SELECT Id FROM PEKERJA WHERE Id = $1 FOR UPDATE`, body.UserID).Scan(&locked)
+	if err != nil {
+		response := &PickJobResponse{
+			Status:  false,
+			Message: err.Error(),
+		}
+
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	calendar, err := loadWorkerCalendar(tx, body.UserID, body.TRID, currentTime)
+	if err != nil {
+		response := &PickJobResponse{
+			Status:  false,
+			Message: err.Error(),
+		}
+
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	start, end := jobSchedule(tglPemesanan, currentTime, sesi)
+	if reason := calendar.conflict(start, end); reason != "" {
+		response := &PickJobResponse{
+			Status:  false,
+			Message: reason,
+		}
+
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	date := start.Format("2006-01-02")
+	time := end.Format("2006-01-02 15:04:05")
 	time_status := currentTime.Format("2006-01-02 15:04:05")
 
 	var value string
-	err = db.QueryRow(`
+	err = tx.QueryRow(`
 	UPDATE TR_PEMESANAN_JASA 
-	SET IdPekerja = $1, TglPekerjaan = $2, WaktuPekerjaan = $3 WHERE Id = $4 RETURNING Id`,
+	SET IdPekerja = $1, TglPekerjaan = $2, WaktuPekerjaan = $3 WHERE Id = $4 AND IdPekerja IS NULL RETURNING Id`,
 		body.UserID, date, time, body.TRID).Scan(&value)
 
+	// Pesanan sudah dipastikan ada, jadi tidak ada baris yang berubah berarti
+	// pekerja lain lebih dulu mengambilnya
 	if err == sql.ErrNoRows {
 		response := &PickJobResponse{
 			Status:  false,
-			Message: "Invalid Credential",
+			Message: "Pekerjaan sudah diambil pekerja lain",
 		}
 
 		json.NewEncoder(w).Encode(response)
@@ -1528,7 +2085,7 @@ func pickAJob(w http.ResponseWriter, r *http.Request) {
 	// a0f51f69-bcb5-45a7-9d55-09c2a15ae4bc | Pesanan selesai
 	// 56bb004e-0b0e-4cb8-982b-98eb4f5dc542 | Pesanan dibatal
 
-	err = db.QueryRow(`
+	err = tx.QueryRow(`
 	INSERT INTO TR_PEMESANAN_STATUS VALUES ($1, $2, $3) RETURNING IdTrPemesanan`,
 		body.TRID, "e88a03a5-7de1-4f5d-9d77-1d8149b0aab6", time_status).Scan(&value)
 	if err == sql.ErrNoRows {
@@ -1549,6 +2106,16 @@ func pickAJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		response := &PickJobResponse{
+			Status:  false,
+			Message: err.Error(),
+		}
+
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	response := &PickJobResponse{
 		Status:  true,
 		Message: "Succes",
@@ -1570,6 +2137,7 @@ func seePekerjaJob(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	body.UserID = currentUserID(r)
 
 	rows, err := db.Query(`SELECT Id FROM TR_PEMESANAN_JASA WHERE IdPekerja = $1`, body.UserID)
 
@@ -1601,11 +2169,13 @@ func seePekerjaJob(w http.ResponseWriter, r *http.Request) {
 			U.Nama,  
 			TJ.Sesi,
 			TJ.TotalBiaya,
-			KJ.NamaKategori
+			KJ.NamaKategori,
+			`+alamatPesananColumns+`
 			FROM TR_PEMESANAN_JASA AS TJ
 			LEFT JOIN SUBKATEGORI_JASA AS SJ ON TJ.IdKategoriJasa = SJ.Id
 			LEFT JOIN KATEGORI_JASA AS KJ ON KJ.Id = SJ.KategoriJasaId
 			LEFT JOIN "user" AS U ON U.Id = TJ.IdPelanggan
+			`+alamatPesananJoin+`
 			WHERE  
 			TJ.Id = $1
 			`, pemesanan).Scan(
@@ -1616,6 +2186,9 @@ func seePekerjaJob(w http.ResponseWriter, r *http.Request) {
 			&response_pesan.Sesi,
 			&response_pesan.Total,
 			&response_pesan.Kategori,
+			&response_pesan.Alamat,
+			&response_pesan.Latitude,
+			&response_pesan.Longitude,
 		)
 
 		db.QueryRow(`
@@ -1728,6 +2301,16 @@ func updatePekerjaJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if idPekerja != currentUserID(r) {
+		response := &JobUpdateStatusResponse{
+			Status:  false,
+			Message: "Pesanan ini bukan milik pekerja",
+		}
+
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	location, err := time.LoadLocation("Asia/Jakarta")
 	if err != nil {
 		response := &JobUpdateStatusResponse{
@@ -1749,8 +2332,17 @@ func updatePekerjaJob(w http.ResponseWriter, r *http.Request) {
 
 		json.NewEncoder(w).Encode(response)
 		return
-	} else if value == 5 {
-		err = db.QueryRow(`
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if value == 5 {
+		err = tx.QueryRow(`
 		UPDATE PEKERJA SET JmlPsnananSelesai = JmlPsnananSelesai + 1 WHERE Id = $1 Returning Id`,
 			idPekerja).Scan(&idPekerja)
 		if err == sql.ErrNoRows {
@@ -1774,7 +2366,7 @@ func updatePekerjaJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var id_tr string
-	err = db.QueryRow(`
+	err = tx.QueryRow(`
 	INSERT INTO TR_PEMESANAN_STATUS VALUES ($1, $2, $3) RETURNING IdTrPemesanan`,
 		body.TRID, status_pesanan[value], time_status).Scan(&id_tr)
 	if err == sql.ErrNoRows {
@@ -1795,6 +2387,30 @@ func updatePekerjaJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Status ke-6 adalah "Pesanan selesai", level pelanggan dievaluasi ulang
+	// dalam transaksi yang sama agar status dan level selalu sejalan
+	if value == 5 {
+		var idPelanggan string
+		err = tx.QueryRow(`SELECT IdPelanggan FROM TR_PEMESANAN_JASA WHERE Id = $1`, body.TRID).Scan(&idPelanggan)
+		if err == nil {
+			err = evaluateCustomerLevel(tx, idPelanggan)
+		}
+		if err != nil {
+			response := &JobUpdateStatusResponse{
+				Status:  false,
+				Message: err.Error(),
+			}
+
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
 	response := &JobUpdateStatusResponse{
 		Status:  true,
 		Message: "Berhasil Memperbaharui data",
@@ -1827,6 +2443,7 @@ func getKategoriFromSub(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	body.Id = currentUserID(r)
 
 	rows, err := db.Query(`SELECT 
 	Id, NamaKategori FROM KATEGORI_JASA 
@@ -1954,25 +2571,34 @@ func CreateTestimoni(db *sql.DB, userID string, pemesananID string, teks string,
 		return fmt.Errorf("pesanan belum selesai, tidak dapat memberikan testimoni")
 	}
 
-	// Pastikan rating valid
-	if rating < 0 {
-		return fmt.Errorf("rating tidak boleh kurang dari 0")
+	if err := validateRating(rating); err != nil {
+		return err
 	}
 
 	// Format tanggal saat ini
 	tgl := time.Now().Format("2006-01-02")
 
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("gagal menyimpan testimoni: %v", err)
+	}
+	defer tx.Rollback()
+
 	// Query untuk memasukkan testimoni
 	query := `
         INSERT INTO sijarta.testimoni (idtrpemesanan, tgl, teks, rating)
         VALUES ($1, $2, $3, $4)
     `
-	_, err = db.Exec(query, pemesananID, tgl, teks, rating)
+	_, err = tx.Exec(query, pemesananID, tgl, teks, rating)
 	if err != nil {
 		return fmt.Errorf("gagal menyimpan testimoni: %v", err)
 	}
 
-	return nil
+	if err := recomputeRatingForPemesanan(tx, pemesananID); err != nil {
+		return fmt.Errorf("gagal memperbarui rating pekerja: %v", err)
+	}
+
+	return commitTestimoni(tx)
 }
 
 func GetTestimoniBySubkategori(db *sql.DB, subkategoriID string) ([]Testimoni, error) {
@@ -2012,17 +2638,27 @@ func DeleteTestimoni(db *sql.DB, userID, pemesananID, tgl string) error {
 		return fmt.Errorf("anda bukan pelanggan yang memesan jasa ini, tidak dapat menghapus testimoni")
 	}
 
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("gagal menghapus testimoni: %v", err)
+	}
+	defer tx.Rollback()
+
 	// Query untuk menghapus testimoni berdasarkan ID pemesanan dan tanggal
 	query := `
         DELETE FROM sijarta.testimoni
         WHERE idtrpemesanan = $1 AND tgl = $2
     `
-	_, err = db.Exec(query, pemesananID, tgl)
+	_, err = tx.Exec(query, pemesananID, tgl)
 	if err != nil {
 		return fmt.Errorf("gagal menghapus testimoni: %v", err)
 	}
 
-	return nil
+	if err := recomputeRatingForPemesanan(tx, pemesananID); err != nil {
+		return fmt.Errorf("gagal memperbarui rating pekerja: %v", err)
+	}
+
+	return commitTestimoni(tx)
 }
 
 // Handler create testimoni
@@ -2046,6 +2682,7 @@ func createTestimoniHandler(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Invalid request body", http.StatusBadRequest)
         return
     }
+    req.UserID = currentUserID(r)
     log.Printf("Received request: %+v", req)
 
     err = CreateTestimoni(db, req.UserID, req.PemesananID, req.Teks, req.Rating)
@@ -2096,6 +2733,7 @@ func deleteTestimoniHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	req.UserID = currentUserID(r)
 
 	err = DeleteTestimoni(db, req.UserID, req.PemesananID, req.Tgl)
 	if err != nil {
@@ -2189,6 +2827,7 @@ func buyVoucherHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	body.UserID = currentUserID(r)
 
 	var potongan float64
 	var minTr int
@@ -2218,6 +2857,27 @@ func buyVoucherHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	benefit, err := pelangganLevelBenefit(db, body.UserID)
+	if err != nil && err != sql.ErrNoRows {
+		response := BuyVoucherResponse{
+			Status:  false,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	if benefit != nil {
+		if !benefit.BisaBeliVoucher {
+			response := BuyVoucherResponse{
+				Status:  false,
+				Message: "Level " + benefit.Nama + " belum dapat membeli voucher",
+			}
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		harga = harga * (100 - benefit.DiskonVoucherPersen) / 100
+	}
+
 	myPayId := "e2ae7f92-eefb-47a7-aa1b-c7d157ab94d7" // UUID metode pembayaran MyPay
 	tglAwal := time.Now()
 	tglAkhir := tglAwal.AddDate(0, 0, jmlHari)
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	otpPurposeRegister = "register"
+	otpPurposeReset    = "reset"
+	otpPurposeNoHPBaru = "nohp_baru"
+
+	otpTTL         = 5 * time.Minute
+	otpResendDelay = time.Minute
+	otpMaxAttempts = 5
+)
+
+var (
+	errOTPInvalid         = errors.New("kode OTP salah")
+	errOTPExpired         = errors.New("kode OTP sudah kedaluwarsa")
+	errOTPTooManyAttempts = errors.New("terlalu banyak percobaan, minta kode OTP baru")
+	errOTPTooSoon         = errors.New("tunggu sebentar sebelum meminta kode OTP baru")
+)
+
+type RequestOTPRequestBody struct {
+	NoHP string `json:"number"`
+}
+
+type VerifyOTPRequestBody struct {
+	NoHP string `json:"number"`
+	OTP  string `json:"otp"`
+}
+
+type VerifyNoHPBaruRequestBody struct {
+	OTP string `json:"otp"`
+}
+
+type RequestOTPResponseBody struct {
+	Status    bool       `json:"status"`
+	Message   string     `json:"message"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func newOTPCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hashOTPCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueOTP membuat kode OTP baru untuk noHP dan purpose tertentu, menimpa
+// kode sebelumnya, lalu mengirimkannya lewat sender.
+func issueOTP(db *sql.DB, sender SMSSender, noHP, purpose string) (time.Time, error) {
+	var createdAt time.Time
+	err := db.QueryRow(`SELECT CreatedAt FROM PHONE_OTP WHERE NoHP = $1 AND Purpose = $2`, noHP, purpose).Scan(&createdAt)
+	if err == nil && time.Since(createdAt) < otpResendDelay {
+		return time.Time{}, errOTPTooSoon
+	} else if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, err
+	}
+
+	code, err := newOTPCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(otpTTL)
+	_, err = db.Exec(`INSERT INTO PHONE_OTP (NoHP, Purpose, CodeHash, Attempts, CreatedAt, ExpiresAt)
+	VALUES ($1, $2, $3, 0, $4, $5)
+	ON CONFLICT (NoHP, Purpose) DO UPDATE SET
+	CodeHash = EXCLUDED.CodeHash, Attempts = 0, CreatedAt = EXCLUDED.CreatedAt, ExpiresAt = EXCLUDED.ExpiresAt`,
+		noHP, purpose, hashOTPCode(code), now, expiresAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	message := fmt.Sprintf("Kode verifikasi SIJARTA Anda: %s. Berlaku %d menit. Jangan berikan kode ini kepada siapa pun.", code, int(otpTTL.Minutes()))
+	if err := sender.Send(noHP, message); err != nil {
+		return time.Time{}, err
+	}
+
+	return expiresAt, nil
+}
+
+// checkOTP memeriksa kode OTP tanpa menghapusnya dan mengembalikan hash kode
+// yang cocok. Percobaan yang salah langsung dihitung di luar transaksi
+// pemanggil agar tidak ikut di-rollback.
+func checkOTP(db *sql.DB, noHP, purpose, code string) (string, error) {
+	var codeHash string
+	var attempts int
+	var expiresAt time.Time
+	err := db.QueryRow(`SELECT CodeHash, Attempts, ExpiresAt FROM PHONE_OTP WHERE NoHP = $1 AND Purpose = $2`, noHP, purpose).
+		Scan(&codeHash, &attempts, &expiresAt)
+	if err == sql.ErrNoRows {
+		return "", errOTPInvalid
+	} else if err != nil {
+		return "", err
+	}
+
+	if attempts >= otpMaxAttempts {
+		return "", errOTPTooManyAttempts
+	}
+	if time.Now().After(expiresAt) {
+		return "", errOTPExpired
+	}
+
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(hashOTPCode(code))) != 1 {
+		_, err = db.Exec(`UPDATE PHONE_OTP SET Attempts = Attempts + 1 WHERE NoHP = $1 AND Purpose = $2`, noHP, purpose)
+		if err != nil {
+			return "", err
+		}
+		return "", errOTPInvalid
+	}
+	return codeHash, nil
+}
+
+// deleteOTP memakai kode yang sudah diperiksa checkOTP. Dengan q berupa
+// transaksi, kode baru benar-benar terpakai jika transaksi di-commit.
+func deleteOTP(q sqlQuerier, noHP, purpose, codeHash string) error {
+	result, err := q.Exec(`DELETE FROM PHONE_OTP WHERE NoHP = $1 AND Purpose = $2 AND CodeHash = $3`, noHP, purpose, codeHash)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errOTPInvalid
+	}
+	return nil
+}
+
+// consumeOTP memeriksa kode OTP dan menghapusnya jika cocok sehingga setiap
+// kode hanya bisa dipakai sekali.
+func consumeOTP(db *sql.DB, noHP, purpose, code string) error {
+	codeHash, err := checkOTP(db, noHP, purpose, code)
+	if err != nil {
+		return err
+	}
+	return deleteOTP(db, noHP, purpose, codeHash)
+}
+
+func requestRegisterOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body RequestOTPRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.NoHP == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var verified bool
+	err = db.QueryRow(`SELECT IsVerified FROM "user" WHERE NoHP = $1`, body.NoHP).Scan(&verified)
+	if err != nil && err != sql.ErrNoRows {
+		json.NewEncoder(w).Encode(&RequestOTPResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	} else if err == nil && verified {
+		json.NewEncoder(w).Encode(&RequestOTPResponseBody{
+			Status:  false,
+			Message: "Nomor HP sudah terdaftar",
+		})
+		return
+	}
+
+	expiresAt, err := issueOTP(db, smsSender, body.NoHP, otpPurposeRegister)
+	if err != nil {
+		json.NewEncoder(w).Encode(&RequestOTPResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(&RequestOTPResponseBody{
+		Status:    true,
+		Message:   "Kode OTP telah dikirim",
+		ExpiresAt: &expiresAt,
+	})
+}
+
+// verifyRegistration menandai akun yang sudah terdaftar tetapi belum
+// membuktikan kepemilikan nomor HP-nya sebagai terverifikasi.
+func verifyRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body VerifyOTPRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.NoHP == "" || body.OTP == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	codeHash, err := checkOTP(db, body.NoHP, otpPurposeRegister, body.OTP)
+	if err != nil {
+		json.NewEncoder(w).Encode(&RegisterResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Kode hanya terpakai jika nomor HP benar-benar terdaftar
+	if err := deleteOTP(tx, body.NoHP, otpPurposeRegister, codeHash); err != nil {
+		json.NewEncoder(w).Encode(&RegisterResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	result, err := tx.Exec(`UPDATE "user" SET IsVerified = TRUE WHERE NoHP = $1`, body.NoHP)
+	if err != nil {
+		json.NewEncoder(w).Encode(&RegisterResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		json.NewEncoder(w).Encode(&RegisterResponseBody{
+			Status:  false,
+			Message: "Nomor HP belum terdaftar",
+		})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&RegisterResponseBody{
+		Status:  true,
+		Message: "Nomor HP berhasil diverifikasi",
+	})
+}
+
+// verifyNoHPChange mengganti NoHP pengguna dengan nomor baru yang diajukan
+// lewat /updateUser setelah OTP yang dikirim ke nomor itu dikonfirmasi.
+func verifyNoHPChange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body VerifyNoHPBaruRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.OTP == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUserID(r)
+	var noHPBaru sql.NullString
+	err = db.QueryRow(`SELECT NoHPBaru FROM "user" WHERE Id = $1`, userID).Scan(&noHPBaru)
+	if err != nil {
+		json.NewEncoder(w).Encode(&UpdateUserResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+	if !noHPBaru.Valid {
+		json.NewEncoder(w).Encode(&UpdateUserResponseBody{
+			Status:  false,
+			Message: "Tidak ada perubahan nomor HP yang menunggu verifikasi",
+		})
+		return
+	}
+
+	codeHash, err := checkOTP(db, noHPBaru.String, otpPurposeNoHPBaru, body.OTP)
+	if err != nil {
+		json.NewEncoder(w).Encode(&UpdateUserResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := deleteOTP(tx, noHPBaru.String, otpPurposeNoHPBaru, codeHash); err != nil {
+		json.NewEncoder(w).Encode(&UpdateUserResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Nomor yang sudah terverifikasi ikut membuktikan kepemilikan akun
+	_, err = tx.Exec(`UPDATE "user" SET NoHP = NoHPBaru, NoHPBaru = NULL, IsVerified = TRUE WHERE Id = $1`, userID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(&UpdateUserResponseBody{
+			Status:  false,
+			Message: "Data profil tidak valid",
+			Errors:  FieldErrors{"number": "Nomor HP sudah terdaftar"},
+		})
+		return
+	} else if err != nil {
+		json.NewEncoder(w).Encode(&UpdateUserResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := revokeAllSessions(tx, userID); err != nil {
+		json.NewEncoder(w).Encode(&UpdateUserResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&UpdateUserResponseBody{
+		Status:  true,
+		Message: "Nomor HP berhasil diganti, silakan login kembali",
+	})
+}
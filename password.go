@@ -0,0 +1,264 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordHashCost  = 12
+	passwordMinLength = 8
+)
+
+// dummyPasswordHash dibandingkan saat nomor HP tidak terdaftar agar lama
+// respons login sama dengan saat password salah, sehingga keberadaan akun
+// tidak bisa ditebak dari waktu respons.
+const dummyPasswordHash = "$2a$12$dRTiaSVGaKQGR3Ob6itwL.uAvoQxyqFiS.eYQODnCXv2LOo4Yi0ci"
+
+var errWeakPassword = errors.New("password minimal 8 karakter dan harus mengandung huruf serta angka")
+
+type ResetPasswordRequestBody struct {
+	NoHP string `json:"number"`
+	OTP  string `json:"otp"`
+	Pwd  string `json:"password"`
+}
+
+type ChangePasswordRequestBody struct {
+	CurrentPwd string `json:"currentPassword"`
+	NewPwd     string `json:"newPassword"`
+}
+
+type PasswordResponseBody struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+}
+
+func hashPassword(pwd string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), passwordHashCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func validatePasswordStrength(pwd string) error {
+	if len(pwd) < passwordMinLength {
+		return errWeakPassword
+	}
+
+	var hasLetter, hasDigit bool
+	for _, c := range pwd {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return errWeakPassword
+	}
+	return nil
+}
+
+func isPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") ||
+		strings.HasPrefix(stored, "$2b$") ||
+		strings.HasPrefix(stored, "$2y$")
+}
+
+// verifyPassword mencocokkan password dengan nilai kolom Pwd. Baris lama yang
+// masih menyimpan password dalam plain text tetap diterima, tetapi needsUpgrade
+// bernilai true agar pemanggil menggantinya dengan hash.
+func verifyPassword(stored, pwd string) (ok bool, needsUpgrade bool) {
+	if isPasswordHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(pwd)) == nil, false
+	}
+
+	ok = subtle.ConstantTimeCompare([]byte(stored), []byte(pwd)) == 1
+	return ok, ok
+}
+
+// upgradePasswordHash mengganti password plain text milik userID dengan hash.
+// Kondisi Pwd = $3 mencegah menimpa password yang baru saja diganti.
+func upgradePasswordHash(db *sql.DB, userID, pwd string) error {
+	hash, err := hashPassword(pwd)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`UPDATE "user" SET Pwd = $1 WHERE Id = $2 AND Pwd = $3`, hash, userID, pwd)
+	return err
+}
+
+// forgotPassword mengirim kode reset ke nomor HP yang terdaftar. Respons selalu
+// sama agar endpoint ini tidak bisa dipakai untuk mengecek nomor terdaftar.
+func forgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body RequestOTPRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.NoHP == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var exist int
+	db.QueryRow(`SELECT 1 FROM "user" WHERE NoHP = $1`, body.NoHP).Scan(&exist)
+	if exist == 1 {
+		if _, err := issueOTP(db, smsSender, body.NoHP, otpPurposeReset); err != nil {
+			log.Printf("Error issuing reset code for %s: %v", body.NoHP, err)
+		}
+	}
+
+	json.NewEncoder(w).Encode(&PasswordResponseBody{
+		Status:  true,
+		Message: "Jika nomor terdaftar, kode reset telah dikirim",
+	})
+}
+
+func resetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body ResetPasswordRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.NoHP == "" || body.OTP == "" || body.Pwd == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validatePasswordStrength(body.Pwd); err != nil {
+		json.NewEncoder(w).Encode(&PasswordResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := consumeOTP(db, body.NoHP, otpPurposeReset, body.OTP); err != nil {
+		json.NewEncoder(w).Encode(&PasswordResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	pwdHash, err := hashPassword(body.Pwd)
+	if err != nil {
+		json.NewEncoder(w).Encode(&PasswordResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var userID string
+	err = db.QueryRow(`UPDATE "user" SET Pwd = $1 WHERE NoHP = $2 RETURNING Id`, pwdHash, body.NoHP).Scan(&userID)
+	if err == sql.ErrNoRows {
+		json.NewEncoder(w).Encode(&PasswordResponseBody{
+			Status:  false,
+			Message: "Nomor HP belum terdaftar",
+		})
+		return
+	} else if err != nil {
+		json.NewEncoder(w).Encode(&PasswordResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := revokeAllSessions(db, userID); err != nil {
+		log.Printf("Error revoking sessions for %s: %v", userID, err)
+	}
+
+	json.NewEncoder(w).Encode(&PasswordResponseBody{
+		Status:  true,
+		Message: "Password berhasil diubah",
+	})
+}
+
+// changePassword mengganti password user yang sedang login setelah password
+// lama dicocokkan. Sesi lain milik user ikut dicabut.
+func changePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body ChangePasswordRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUserID(r)
+
+	var storedPwd string
+	err = db.QueryRow(`SELECT Pwd FROM "user" WHERE Id = $1`, userID).Scan(&storedPwd)
+	if err != nil {
+		json.NewEncoder(w).Encode(&PasswordResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if ok, _ := verifyPassword(storedPwd, body.CurrentPwd); !ok {
+		json.NewEncoder(w).Encode(&PasswordResponseBody{
+			Status:  false,
+			Message: "Password lama salah",
+		})
+		return
+	}
+
+	if err := validatePasswordStrength(body.NewPwd); err != nil {
+		json.NewEncoder(w).Encode(&PasswordResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	pwdHash, err := hashPassword(body.NewPwd)
+	if err != nil {
+		json.NewEncoder(w).Encode(&PasswordResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	_, err = db.Exec(`UPDATE "user" SET Pwd = $1 WHERE Id = $2`, pwdHash, userID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&PasswordResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := revokeOtherSessions(db, userID, currentSessionID(r)); err != nil {
+		log.Printf("Error revoking sessions for %s: %v", userID, err)
+	}
+
+	json.NewEncoder(w).Encode(&PasswordResponseBody{
+		Status:  true,
+		Message: "Password berhasil diubah",
+	})
+}
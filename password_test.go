@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Login ke nomor yang tidak terdaftar hanya sama lambatnya jika hash dummy
+// memakai cost yang sama dengan hash password sungguhan.
+func TestDummyPasswordHashCost(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	if err != nil {
+		t.Fatalf("dummyPasswordHash bukan hash bcrypt: %v", err)
+	}
+	if cost != passwordHashCost {
+		t.Errorf("cost dummyPasswordHash = %d, want %d", cost, passwordHashCost)
+	}
+}
+
+func TestValidatePasswordStrength(t *testing.T) {
+	tests := []struct {
+		name string
+		pwd  string
+		want error
+	}{
+		{"valid", "rahasia123", nil},
+		{"tepat 8 karakter", "abcdef12", nil},
+		{"huruf non-ASCII", "kataßandi1", nil},
+		{"kosong", "", errWeakPassword},
+		{"terlalu pendek", "abc123", errWeakPassword},
+		{"tanpa angka", "rahasiasekali", errWeakPassword},
+		{"tanpa huruf", "1234567890", errWeakPassword},
+		{"hanya simbol", "!@#$%^&*()", errWeakPassword},
+	}
+	for _, tt := range tests {
+		if got := validatePasswordStrength(tt.pwd); got != tt.want {
+			t.Errorf("%s: validatePasswordStrength(%q) = %v, want %v", tt.name, tt.pwd, got, tt.want)
+		}
+	}
+}
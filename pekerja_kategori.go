@@ -0,0 +1,163 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type KategoriItem struct {
+	Id           string `json:"id"`
+	NamaKategori string `json:"nama"`
+}
+
+type PekerjaKategoriResponseBody struct {
+	Status   bool           `json:"status"`
+	Message  string         `json:"message"`
+	Kategori []KategoriItem `json:"kategori"`
+}
+
+type PekerjaKategoriRequestBody struct {
+	KategoriId string `json:"kategoriId"`
+}
+
+func listPekerjaKategori(db *sql.DB, pekerjaID string) ([]KategoriItem, error) {
+	rows, err := db.Query(`SELECT k.Id, k.NamaKategori
+	FROM PEKERJA_KATEGORI_JASA pk
+	JOIN KATEGORI_JASA k ON k.Id = pk.KategoriJasaId
+	WHERE pk.PekerjaId = $1
+	ORDER BY k.NamaKategori`, pekerjaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kategori := []KategoriItem{}
+	for rows.Next() {
+		var item KategoriItem
+		if err := rows.Scan(&item.Id, &item.NamaKategori); err != nil {
+			return nil, err
+		}
+		kategori = append(kategori, item)
+	}
+	return kategori, rows.Err()
+}
+
+func writePekerjaKategori(w http.ResponseWriter, pekerjaID, message string) {
+	kategori, err := listPekerjaKategori(db, pekerjaID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&PekerjaKategoriResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(&PekerjaKategoriResponseBody{
+		Status:   true,
+		Message:  message,
+		Kategori: kategori,
+	})
+}
+
+func decodePekerjaKategoriRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var body PekerjaKategoriRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+
+	if _, err := uuid.Parse(body.KategoriId); err != nil {
+		http.Error(w, "Invalid kategoriId format", http.StatusBadRequest)
+		return "", false
+	}
+	return body.KategoriId, true
+}
+
+func getPekerjaKategori(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writePekerjaKategori(w, currentUserID(r), "Berhasil mendapatkan data")
+}
+
+func addPekerjaKategori(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kategoriID, ok := decodePekerjaKategoriRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var exist int
+	err := db.QueryRow(`SELECT 1 FROM KATEGORI_JASA WHERE Id = $1 AND ArchivedAt IS NULL`, kategoriID).Scan(&exist)
+	if err == sql.ErrNoRows {
+		json.NewEncoder(w).Encode(&PekerjaKategoriResponseBody{
+			Status:  false,
+			Message: "Kategori jasa tidak ditemukan",
+		})
+		return
+	} else if err != nil {
+		json.NewEncoder(w).Encode(&PekerjaKategoriResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	_, err = db.Exec(`INSERT INTO PEKERJA_KATEGORI_JASA (PekerjaId, KategoriJasaId)
+	SELECT $1, $2
+	WHERE NOT EXISTS (SELECT 1 FROM PEKERJA_KATEGORI_JASA WHERE PekerjaId = $1 AND KategoriJasaId = $2)`,
+		currentUserID(r), kategoriID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&PekerjaKategoriResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	catalogResponses.invalidate()
+	writePekerjaKategori(w, currentUserID(r), "Kategori berhasil ditambahkan")
+}
+
+func removePekerjaKategori(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kategoriID, ok := decodePekerjaKategoriRequest(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec(`DELETE FROM PEKERJA_KATEGORI_JASA WHERE PekerjaId = $1 AND KategoriJasaId = $2`,
+		currentUserID(r), kategoriID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&PekerjaKategoriResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		json.NewEncoder(w).Encode(&PekerjaKategoriResponseBody{
+			Status:  false,
+			Message: "Kategori tidak terdaftar untuk pekerja ini",
+		})
+		return
+	}
+
+	catalogResponses.invalidate()
+	writePekerjaKategori(w, currentUserID(r), "Kategori berhasil dihapus")
+}
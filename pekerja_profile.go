@@ -0,0 +1,109 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const profileTestimoniLimit = 10
+
+// PekerjaProfileResponseBody hanya berisi data yang boleh dilihat pelanggan.
+// NPWP, data bank dan NoHP sengaja tidak ada di sini.
+type PekerjaProfileResponseBody struct {
+	Status            bool           `json:"status"`
+	Message           string         `json:"message"`
+	Id                string         `json:"id,omitempty"`
+	Nama              string         `json:"name,omitempty"`
+	LinkFoto          string         `json:"link,omitempty"`
+	LinkFotoThumbnail string         `json:"thumbnail,omitempty"`
+	Rating            float64        `json:"rating"`
+	JmlPsnananSelesai int            `json:"amount"`
+	Kategori          []KategoriItem `json:"kategori"`
+	Testimoni         []Testimoni    `json:"testimoni"`
+}
+
+func listPekerjaTestimoni(db *sql.DB, pekerjaID string, limit int) ([]Testimoni, error) {
+	rows, err := db.Query(`SELECT t.idtrpemesanan, t.tgl, t.teks, t.rating
+	FROM sijarta.testimoni t
+	JOIN sijarta.tr_pemesanan_jasa pj ON t.idtrpemesanan = pj.id
+	WHERE pj.idpekerja = $1
+	ORDER BY t.tgl DESC
+	LIMIT $2`, pekerjaID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	testimoni := []Testimoni{}
+	for rows.Next() {
+		var t Testimoni
+		if err := rows.Scan(&t.IdTrPemesanan, &t.Tgl, &t.Teks, &t.Rating); err != nil {
+			return nil, err
+		}
+		testimoni = append(testimoni, t)
+	}
+	return testimoni, rows.Err()
+}
+
+func getPekerjaProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pekerjaID := r.URL.Query().Get("id")
+	if _, err := uuid.Parse(pekerjaID); err != nil {
+		http.Error(w, "Invalid id format", http.StatusBadRequest)
+		return
+	}
+
+	response := &PekerjaProfileResponseBody{Id: pekerjaID}
+	err := db.QueryRow(`SELECT u.Nama, p.LinkFoto, COALESCE(p.LinkFotoThumbnail, ''), p.Rating, p.JmlPsnananSelesai
+	FROM PEKERJA p
+	JOIN "user" u ON u.Id = p.Id
+	WHERE p.Id = $1 AND u.DeactivatedAt IS NULL`, pekerjaID).Scan(
+		&response.Nama,
+		&response.LinkFoto,
+		&response.LinkFotoThumbnail,
+		&response.Rating,
+		&response.JmlPsnananSelesai)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(&PekerjaProfileResponseBody{
+			Status:  false,
+			Message: "Pekerja tidak ditemukan",
+		})
+		return
+	} else if err != nil {
+		json.NewEncoder(w).Encode(&PekerjaProfileResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response.Kategori, err = listPekerjaKategori(db, pekerjaID)
+	if err != nil {
+		json.NewEncoder(w).Encode(&PekerjaProfileResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response.Testimoni, err = listPekerjaTestimoni(db, pekerjaID, profileTestimoniLimit)
+	if err != nil {
+		json.NewEncoder(w).Encode(&PekerjaProfileResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response.Status = true
+	response.Message = "Berhasil mendapatkan data"
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxPhotoSize      = 5 << 20
+	thumbnailMaxSide  = 256
+	photoJPEGQuality  = 90
+	thumbnailQuality  = 80
+	maxPhotoDimension = 4096
+)
+
+type UploadPhotoResponseBody struct {
+	Status        bool   `json:"status"`
+	Message       string `json:"message"`
+	LinkFoto      string `json:"link,omitempty"`
+	LinkThumbnail string `json:"thumbnail,omitempty"`
+}
+
+// thumbnail memperkecil img dengan rata-rata area sehingga sisi terpanjangnya
+// paling besar maxSide piksel.
+func thumbnail(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSide && height <= maxSide {
+		return img
+	}
+
+	newWidth, newHeight := maxSide, maxSide
+	if width > height {
+		newHeight = height * maxSide / width
+	} else {
+		newWidth = width * maxSide / height
+	}
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0 := bounds.Min.Y + y*height/newHeight
+		y1 := bounds.Min.Y + (y+1)*height/newHeight
+		for x := 0; x < newWidth; x++ {
+			x0 := bounds.Min.X + x*width/newWidth
+			x1 := bounds.Min.X + (x+1)*width/newWidth
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// encodePhoto menulis ulang gambar hasil decode. Karena hanya piksel yang
+// ditulis, metadata EXIF dari file asli ikut terbuang.
+func encodePhoto(img image.Image, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	}
+	return buf.Bytes(), err
+}
+
+func uploadPekerjaPhoto(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhotoSize+(1<<20))
+	file, _, err := r.FormFile("photo")
+	if err != nil {
+		http.Error(w, "Field photo wajib berupa file dengan ukuran maksimal 5 MB", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxPhotoSize+1))
+	if err != nil {
+		http.Error(w, "Gagal membaca file", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxPhotoSize {
+		http.Error(w, "Ukuran foto maksimal 5 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		http.Error(w, "Foto harus berformat JPEG atau PNG", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width > maxPhotoDimension || config.Height > maxPhotoDimension {
+		http.Error(w, "Dimensi foto tidak valid", http.StatusBadRequest)
+		return
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, "Foto tidak dapat dibaca", http.StatusBadRequest)
+		return
+	}
+
+	photo, err := encodePhoto(img, format, photoJPEGQuality)
+	if err != nil {
+		http.Error(w, "Gagal memproses foto", http.StatusInternalServerError)
+		return
+	}
+	thumb, err := encodePhoto(thumbnail(img, thumbnailMaxSide), format, thumbnailQuality)
+	if err != nil {
+		http.Error(w, "Gagal membuat thumbnail", http.StatusInternalServerError)
+		return
+	}
+
+	ext := ".jpg"
+	if format == "png" {
+		ext = ".png"
+	}
+	userID := currentUserID(r)
+	name := userID + "-" + uuid.New().String()
+	if err := fileStorage.Save(name+ext, photo); err != nil {
+		http.Error(w, "Gagal menyimpan foto", http.StatusInternalServerError)
+		return
+	}
+	if err := fileStorage.Save(name+"-thumb"+ext, thumb); err != nil {
+		http.Error(w, "Gagal menyimpan thumbnail", http.StatusInternalServerError)
+		return
+	}
+
+	linkFoto := fileStorage.URL(name + ext)
+	linkThumbnail := fileStorage.URL(name + "-thumb" + ext)
+	oldFoto, oldThumbnail, err := replacePekerjaPhoto(db, userID, linkFoto, linkThumbnail)
+	if err != nil {
+		deletePekerjaPhotos(userID, linkFoto, linkThumbnail)
+		json.NewEncoder(w).Encode(&UploadPhotoResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+	catalogResponses.invalidate()
+	deletePekerjaPhotos(userID, oldFoto, oldThumbnail)
+
+	json.NewEncoder(w).Encode(&UploadPhotoResponseBody{
+		Status:        true,
+		Message:       "Foto berhasil diunggah",
+		LinkFoto:      linkFoto,
+		LinkThumbnail: linkThumbnail,
+	})
+}
+
+// replacePekerjaPhoto menyimpan link foto baru dan mengembalikan link foto
+// lama agar filenya bisa dihapus.
+func replacePekerjaPhoto(db *sql.DB, userID, linkFoto, linkThumbnail string) (string, string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	var oldFoto, oldThumbnail string
+	err = tx.QueryRow(`SELECT COALESCE(LinkFoto, ''), COALESCE(LinkFotoThumbnail, '') FROM PEKERJA WHERE Id = $1 FOR UPDATE`, userID).
+		Scan(&oldFoto, &oldThumbnail)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = tx.Exec(`UPDATE PEKERJA SET LinkFoto = $1, LinkFotoThumbnail = $2 WHERE Id = $3`, linkFoto, linkThumbnail, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return oldFoto, oldThumbnail, tx.Commit()
+}
+
+// deletePekerjaPhotos menghapus file foto milik userID yang ditunjuk oleh
+// links. Link yang bukan unggahan pengguna tersebut, misalnya foto lama yang
+// disimpan di luar, dilewati. Kegagalan hanya dicatat karena data di database
+// sudah tidak lagi menunjuk ke file tersebut.
+func deletePekerjaPhotos(userID string, links ...string) {
+	for _, link := range links {
+		name := path.Base(link)
+		if link == "" || !strings.HasPrefix(name, userID+"-") {
+			continue
+		}
+		if err := fileStorage.Delete(name); err != nil {
+			log.Printf("Error deleting photo %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	aturanAkhirPekan      = "akhir_pekan"
+	aturanHariLibur       = "hari_libur"
+	aturanJamSibuk        = "jam_sibuk"
+	aturanMinimumKategori = "minimum_kategori"
+	kodeRincianDiskon     = "diskon"
+)
+
+var (
+	errDiskonTidakBerlaku = errors.New("kode diskon tidak ditemukan atau sudah tidak berlaku")
+	errDiskonMinPesanan   = errors.New("jumlah pesanan belum memenuhi syarat kode diskon")
+)
+
+type PriceLineItem struct {
+	Kode       string  `json:"kode"`
+	Keterangan string  `json:"keterangan"`
+	Jumlah     float64 `json:"jumlah"`
+}
+
+type PriceQuote struct {
+	SesiId        int             `json:"sesiId"`
+	NamaSesi      string          `json:"namaSesi"`
+	SubkategoriId string          `json:"subkategoriId"`
+	KategoriId    string          `json:"kategoriId"`
+	Tanggal       string          `json:"tanggal"`
+	Jam           string          `json:"jam,omitempty"`
+	HargaDasar    float64         `json:"hargaDasar"`
+	Rincian       []PriceLineItem `json:"rincian"`
+	Total         float64         `json:"total"`
+}
+
+type PriceQuoteResponseBody struct {
+	Status  bool        `json:"status"`
+	Message string      `json:"message"`
+	Errors  FieldErrors `json:"errors,omitempty"`
+	Quote   *PriceQuote `json:"quote,omitempty"`
+}
+
+// pricingRule adalah satu baris ATURAN_HARGA. Pengali dipakai oleh aturan
+// tambahan biaya (1.2 berarti +20% dari harga dasar), HargaMinimum oleh
+// aturan minimum_kategori, dan JamMulai/JamSelesai oleh aturan jam_sibuk.
+type pricingRule struct {
+	Kode         string
+	Jenis        string
+	Keterangan   string
+	Pengali      float64
+	HargaMinimum sql.NullFloat64
+	JamMulai     sql.NullString
+	JamSelesai   sql.NullString
+}
+
+// pricingWaktu adalah waktu layanan yang dihargai. Jam kosong berarti jam
+// layanan tidak diketahui sehingga aturan jam_sibuk tidak berlaku.
+type pricingWaktu struct {
+	Tanggal time.Time
+	Jam     string
+}
+
+func roundRupiah(value float64) float64 {
+	return math.Round(value)
+}
+
+// parsePricingWaktu memvalidasi tanggal (YYYY-MM-DD) dan jam (HH:MM,
+// opsional) layanan.
+func parsePricingWaktu(errs FieldErrors, tanggal, jam string) pricingWaktu {
+	var waktu pricingWaktu
+	validateRequired(errs, "tanggal", tanggal, "Tanggal wajib diisi")
+	if tanggal != "" {
+		parsed, err := time.Parse("2006-01-02", tanggal)
+		if err != nil {
+			errs.add("tanggal", "Format tanggal harus YYYY-MM-DD")
+		}
+		waktu.Tanggal = parsed
+	}
+	if jam != "" {
+		if _, err := time.Parse("15:04", jam); err != nil {
+			errs.add("jam", "Format jam harus HH:MM")
+		}
+		waktu.Jam = jam
+	}
+	return waktu
+}
+
+// validateTanggalPesanan menolak tanggal layanan sebelum hari ini. now harus
+// sudah dalam zona Asia/Jakarta.
+func validateTanggalPesanan(errs FieldErrors, waktu pricingWaktu, now time.Time) {
+	if _, invalid := errs["tanggal"]; invalid {
+		return
+	}
+	if waktu.Tanggal.Before(dateOnly(wallClock(now))) {
+		errs.add("tanggal", "Tanggal tidak boleh di masa lalu")
+	}
+}
+
+// jamDalamRentang memeriksa jam terhadap rentang [mulai, selesai). Rentang
+// yang melewati tengah malam, misalnya 22:00 sampai 02:00, juga didukung.
+// Semua nilai berformat HH:MM sehingga cukup dibandingkan sebagai string.
+func jamDalamRentang(jam, mulai, selesai string) bool {
+	if mulai <= selesai {
+		return jam >= mulai && jam < selesai
+	}
+	return jam >= mulai || jam < selesai
+}
+
+func (rule pricingRule) appliesTo(waktu pricingWaktu, libur bool) bool {
+	switch rule.Jenis {
+	case aturanAkhirPekan:
+		day := waktu.Tanggal.Weekday()
+		return day == time.Saturday || day == time.Sunday
+	case aturanHariLibur:
+		return libur
+	case aturanJamSibuk:
+		return waktu.Jam != "" && rule.JamMulai.Valid && rule.JamSelesai.Valid &&
+			jamDalamRentang(waktu.Jam, rule.JamMulai.String, rule.JamSelesai.String)
+	}
+	return false
+}
+
+// applyPricingRules menghitung rincian harga dari harga dasar. Setiap tambahan
+// biaya dihitung dari harga dasar (tidak berlipat), lalu harga minimum
+// kategori tertinggi yang berlaku menaikkan total jika masih di bawahnya.
+func applyPricingRules(namaSesi string, hargaDasar float64, waktu pricingWaktu, rules []pricingRule, libur bool) ([]PriceLineItem, float64) {
+	rincian := []PriceLineItem{{
+		Kode:       "harga_dasar",
+		Keterangan: "Harga " + namaSesi,
+		Jumlah:     hargaDasar,
+	}}
+	total := hargaDasar
+
+	var minimum *pricingRule
+	for i, rule := range rules {
+		if rule.Jenis == aturanMinimumKategori {
+			if rule.HargaMinimum.Valid && (minimum == nil || rule.HargaMinimum.Float64 > minimum.HargaMinimum.Float64) {
+				minimum = &rules[i]
+			}
+			continue
+		}
+		if !rule.appliesTo(waktu, libur) {
+			continue
+		}
+		jumlah := roundRupiah(hargaDasar * (rule.Pengali - 1))
+		if jumlah == 0 {
+			continue
+		}
+		rincian = append(rincian, PriceLineItem{
+			Kode:       rule.Kode,
+			Keterangan: rule.Keterangan,
+			Jumlah:     jumlah,
+		})
+		total += jumlah
+	}
+
+	if minimum != nil && total < minimum.HargaMinimum.Float64 {
+		jumlah := roundRupiah(minimum.HargaMinimum.Float64 - total)
+		rincian = append(rincian, PriceLineItem{
+			Kode:       minimum.Kode,
+			Keterangan: minimum.Keterangan,
+			Jumlah:     jumlah,
+		})
+		total += jumlah
+	}
+	return rincian, roundRupiah(total)
+}
+
+// diskonPesanan adalah kode voucher atau promo yang sudah divalidasi untuk
+// satu pesanan. VoucherPembelianId terisi jika kode berasal dari voucher yang
+// dibeli pelanggan, karena pemakaiannya harus dicatat.
+type diskonPesanan struct {
+	Kode               string
+	Potongan           float64
+	VoucherPembelianId string
+}
+
+// applyDiskon mengurangi total dengan potongan diskon sebagai baris rincian
+// bernilai negatif. Potongan tidak pernah membuat total di bawah nol.
+func applyDiskon(rincian []PriceLineItem, total float64, diskon diskonPesanan) ([]PriceLineItem, float64) {
+	potongan := roundRupiah(math.Min(diskon.Potongan, total))
+	if potongan <= 0 {
+		return rincian, total
+	}
+	rincian = append(rincian, PriceLineItem{
+		Kode:       kodeRincianDiskon,
+		Keterangan: "Diskon " + diskon.Kode,
+		Jumlah:     -potongan,
+	})
+	return rincian, total - potongan
+}
+
+// loadDiskon memvalidasi kode diskon untuk pelanggan pada tanggal layanan.
+// Promo berlaku sampai TglAkhirBerlaku, sedangkan voucher harus sudah dibeli
+// pelanggan, belum kedaluwarsa dan kuotanya masih tersisa. Dengan lock, baris
+// pembelian voucher dikunci sehingga q harus berupa transaksi yang juga
+// mencatat pemakaiannya. Kode yang tidak berlaku menghasilkan
+// errDiskonTidakBerlaku atau errDiskonMinPesanan.
+func loadDiskon(q sqlQuerier, pelangganID, kode string, tanggal time.Time, lock bool) (*diskonPesanan, error) {
+	diskon := &diskonPesanan{Kode: kode}
+	var minTrPemesanan int
+	err := q.QueryRow(`SELECT potongan, mintrpemesanan FROM sijarta.diskon WHERE kode = $1`, kode).Scan(
+		&diskon.Potongan, &minTrPemesanan)
+	if err == sql.ErrNoRows {
+		return nil, errDiskonTidakBerlaku
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Dihitung dari tabel yang diisi createPesanan
+	var jumlahPesanan int
+	err = q.QueryRow(`SELECT COUNT(*) FROM pesanan WHERE id_user = $1`, pelangganID).Scan(&jumlahPesanan)
+	if err != nil {
+		return nil, err
+	}
+	if jumlahPesanan < minTrPemesanan {
+		return nil, errDiskonMinPesanan
+	}
+
+	var tglAkhirBerlaku time.Time
+	err = q.QueryRow(`SELECT tglakhirberlaku FROM sijarta.promo WHERE kode = $1`, kode).Scan(&tglAkhirBerlaku)
+	if err == nil {
+		if tanggal.After(tglAkhirBerlaku) {
+			return nil, errDiskonTidakBerlaku
+		}
+		return diskon, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	voucherQuery := `SELECT pv.id FROM sijarta.tr_pembelian_voucher pv
+	JOIN sijarta.voucher v ON v.kode = pv.idvoucher
+	WHERE pv.idpelanggan = $1 AND pv.idvoucher = $2
+	AND pv.tglakhir >= NOW() AND pv.telahdigunakan < v.kuotapenggunaan
+	ORDER BY pv.tglakhir
+	LIMIT 1`
+	if lock {
+		voucherQuery += ` FOR UPDATE OF pv`
+	}
+	err = q.QueryRow(voucherQuery, pelangganID, kode).Scan(&diskon.VoucherPembelianId)
+	if err == sql.ErrNoRows {
+		return nil, errDiskonTidakBerlaku
+	}
+	if err != nil {
+		return nil, err
+	}
+	return diskon, nil
+}
+
+// useVoucher mencatat satu kali pemakaian voucher yang dibeli pelanggan.
+func useVoucher(q sqlQuerier, diskon *diskonPesanan) error {
+	if diskon.VoucherPembelianId == "" {
+		return nil
+	}
+	_, err := q.Exec(`UPDATE sijarta.tr_pembelian_voucher SET telahdigunakan = telahdigunakan + 1 WHERE id = $1`,
+		diskon.VoucherPembelianId)
+	return err
+}
+
+// loadPricingRules mengambil aturan aktif yang berlaku untuk semua kategori
+// atau khusus untuk kategoriID.
+func loadPricingRules(q sqlQuerier, kategoriID string) ([]pricingRule, error) {
+	rows, err := q.Query(`SELECT Kode, Jenis, Keterangan, Pengali, HargaMinimum,
+	to_char(JamMulai, 'HH24:MI'), to_char(JamSelesai, 'HH24:MI')
+	FROM ATURAN_HARGA
+	WHERE Aktif AND (KategoriJasaId IS NULL OR KategoriJasaId = $1)
+	ORDER BY Urutan, Kode`, kategoriID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []pricingRule
+	for rows.Next() {
+		var rule pricingRule
+		err := rows.Scan(&rule.Kode, &rule.Jenis, &rule.Keterangan, &rule.Pengali, &rule.HargaMinimum, &rule.JamMulai, &rule.JamSelesai)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func isHariLibur(q sqlQuerier, tanggal time.Time) (bool, error) {
+	var libur bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM HARI_LIBUR WHERE Tgl = $1)`,
+		tanggal.Format("2006-01-02")).Scan(&libur)
+	return libur, err
+}
+
+// quotePrice menghitung harga sesi untuk waktu layanan tertentu. Sesi yang
+// tidak ada atau sudah diarsipkan menghasilkan sql.ErrNoRows.
+func quotePrice(q sqlQuerier, sesiID int, waktu pricingWaktu) (*PriceQuote, error) {
+	quote := &PriceQuote{
+		SesiId:  sesiID,
+		Tanggal: waktu.Tanggal.Format("2006-01-02"),
+		Jam:     waktu.Jam,
+	}
+	err := q.QueryRow(`SELECT sesi.nama_sesi, sesi.harga, s.Id, k.Id
+	FROM sesi_layanan sesi
+	JOIN SUBKATEGORI_JASA s ON s.Id = sesi.id_subkategori
+	JOIN KATEGORI_JASA k ON k.Id = s.KategoriJasaId
+	WHERE sesi.id = $1 AND sesi.archived_at IS NULL
+	AND s.ArchivedAt IS NULL AND k.ArchivedAt IS NULL`, sesiID).Scan(
+		&quote.NamaSesi, &quote.HargaDasar, &quote.SubkategoriId, &quote.KategoriId)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := loadPricingRules(q, quote.KategoriId)
+	if err != nil {
+		return nil, err
+	}
+	libur, err := isHariLibur(q, waktu.Tanggal)
+	if err != nil {
+		return nil, err
+	}
+
+	quote.Rincian, quote.Total = applyPricingRules(quote.NamaSesi, quote.HargaDasar, waktu, rules, libur)
+	return quote, nil
+}
+
+// getPriceQuote menampilkan rincian harga sebelum pelanggan memesan, dengan
+// perhitungan yang sama seperti saat pesanan dibuat. Kode diskon hanya bisa
+// dipakai pelanggan yang login dan di sini tidak mengurangi kuota voucher.
+func getPriceQuote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	values := r.URL.Query()
+	errs := FieldErrors{}
+	sesiID, err := strconv.Atoi(values.Get("sesi_id"))
+	if err != nil || sesiID < 1 {
+		errs.add("sesi_id", "Sesi wajib dipilih")
+	}
+	waktu := parsePricingWaktu(errs, strings.TrimSpace(values.Get("tanggal")), strings.TrimSpace(values.Get("jam")))
+	kodeDiskon := strings.TrimSpace(values.Get("kode_diskon"))
+	if kodeDiskon != "" && currentUserID(r) == "" {
+		errs.add("kode_diskon", "Login terlebih dahulu untuk memakai kode diskon")
+	}
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(&PriceQuoteResponseBody{
+			Status:  false,
+			Message: "Parameter harga tidak valid",
+			Errors:  errs,
+		})
+		return
+	}
+
+	quote, err := quotePrice(db, sesiID, waktu)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(&PriceQuoteResponseBody{
+			Status:  false,
+			Message: "Sesi tidak ditemukan",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&PriceQuoteResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if kodeDiskon != "" {
+		diskon, err := loadDiskon(db, currentUserID(r), kodeDiskon, waktu.Tanggal, false)
+		if err == errDiskonTidakBerlaku || err == errDiskonMinPesanan {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(&PriceQuoteResponseBody{
+				Status:  false,
+				Message: "Parameter harga tidak valid",
+				Errors:  FieldErrors{"kode_diskon": err.Error()},
+			})
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&PriceQuoteResponseBody{
+				Status:  false,
+				Message: err.Error(),
+			})
+			return
+		}
+		quote.Rincian, quote.Total = applyDiskon(quote.Rincian, quote.Total, *diskon)
+	}
+
+	json.NewEncoder(w).Encode(&PriceQuoteResponseBody{
+		Status:  true,
+		Message: "Berhasil mendapatkan data",
+		Quote:   quote,
+	})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestJamDalamRentang(t *testing.T) {
+	tests := []struct {
+		jam, mulai, selesai string
+		want                bool
+	}{
+		{"17:00", "17:00", "20:00", true},
+		{"19:59", "17:00", "20:00", true},
+		{"20:00", "17:00", "20:00", false},
+		{"16:59", "17:00", "20:00", false},
+		// Rentang yang melewati tengah malam
+		{"22:00", "22:00", "02:00", true},
+		{"23:59", "22:00", "02:00", true},
+		{"00:00", "22:00", "02:00", true},
+		{"01:59", "22:00", "02:00", true},
+		{"02:00", "22:00", "02:00", false},
+		{"21:59", "22:00", "02:00", false},
+		{"12:00", "22:00", "02:00", false},
+		// Rentang berakhir tepat tengah malam
+		{"23:30", "22:00", "00:00", true},
+		{"00:00", "22:00", "00:00", false},
+	}
+	for _, tt := range tests {
+		if got := jamDalamRentang(tt.jam, tt.mulai, tt.selesai); got != tt.want {
+			t.Errorf("jamDalamRentang(%q, %q, %q) = %v, want %v", tt.jam, tt.mulai, tt.selesai, got, tt.want)
+		}
+	}
+}
+
+func TestApplyPricingRules(t *testing.T) {
+	jam := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+	akhirPekan := pricingRule{Kode: "akhir_pekan", Jenis: aturanAkhirPekan, Pengali: 1.1}
+	hariLibur := pricingRule{Kode: "hari_libur", Jenis: aturanHariLibur, Pengali: 1.25}
+	jamSibuk := pricingRule{Kode: "jam_sibuk", Jenis: aturanJamSibuk, Pengali: 1.15, JamMulai: jam("17:00"), JamSelesai: jam("20:00")}
+	minimum := func(harga float64) pricingRule {
+		return pricingRule{Kode: "minimum", Jenis: aturanMinimumKategori, Pengali: 1,
+			HargaMinimum: sql.NullFloat64{Float64: harga, Valid: true}}
+	}
+	semua := []pricingRule{akhirPekan, hariLibur, jamSibuk}
+
+	tests := []struct {
+		name    string
+		tanggal string
+		jam     string
+		libur   bool
+		rules   []pricingRule
+		want    float64
+		kode    []string
+	}{
+		{"tanpa aturan", "2024-05-15", "10:00", false, nil, 100000, nil},
+		{"hari kerja biasa", "2024-05-15", "10:00", false, semua, 100000, nil},
+		{"akhir pekan", "2024-05-18", "10:00", false, semua, 110000, []string{"akhir_pekan"}},
+		{"hari libur", "2024-05-15", "10:00", true, semua, 125000, []string{"hari_libur"}},
+		{"jam sibuk", "2024-05-15", "18:00", false, semua, 115000, []string{"jam_sibuk"}},
+		{"jam tidak diketahui", "2024-05-15", "", false, semua, 100000, nil},
+		{"akhir pekan, libur dan jam sibuk tidak berlipat", "2024-05-19", "18:00", true, semua, 150000,
+			[]string{"akhir_pekan", "hari_libur", "jam_sibuk"}},
+		{"minimum menaikkan total", "2024-05-15", "10:00", false, []pricingRule{minimum(150000)}, 150000, []string{"minimum"}},
+		{"minimum tidak berlaku jika total sudah lebih tinggi", "2024-05-19", "18:00", true,
+			append([]pricingRule{minimum(120000)}, semua...), 150000, []string{"akhir_pekan", "hari_libur", "jam_sibuk"}},
+		{"minimum tertinggi yang dipakai", "2024-05-18", "10:00", false,
+			[]pricingRule{minimum(120000), akhirPekan, minimum(130000)}, 130000, []string{"akhir_pekan", "minimum"}},
+		{"pengali satu diabaikan", "2024-05-18", "10:00", false,
+			[]pricingRule{{Kode: "netral", Jenis: aturanAkhirPekan, Pengali: 1}}, 100000, nil},
+	}
+	for _, tt := range tests {
+		errs := FieldErrors{}
+		waktu := parsePricingWaktu(errs, tt.tanggal, tt.jam)
+		if len(errs) > 0 {
+			t.Fatalf("%s: parsePricingWaktu: %v", tt.name, errs)
+		}
+
+		rincian, total := applyPricingRules("Sesi 1", 100000, waktu, tt.rules, tt.libur)
+		if total != tt.want {
+			t.Errorf("%s: total = %v, want %v", tt.name, total, tt.want)
+		}
+
+		var sum float64
+		var kode []string
+		for i, item := range rincian {
+			sum += item.Jumlah
+			if i > 0 {
+				kode = append(kode, item.Kode)
+			}
+		}
+		if rincian[0].Kode != "harga_dasar" || sum != total {
+			t.Errorf("%s: rincian %v tidak sesuai dengan total %v", tt.name, rincian, total)
+		}
+		if len(kode) != len(tt.kode) {
+			t.Errorf("%s: kode rincian = %v, want %v", tt.name, kode, tt.kode)
+			continue
+		}
+		for i := range kode {
+			if kode[i] != tt.kode[i] {
+				t.Errorf("%s: kode rincian = %v, want %v", tt.name, kode, tt.kode)
+				break
+			}
+		}
+	}
+}
+
+func TestApplyDiskon(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    float64
+		potongan float64
+		want     float64
+		rincian  int
+	}{
+		{"potongan sebagian", 150000, 20000, 130000, 2},
+		{"potongan tidak melebihi total", 15000, 20000, 0, 2},
+		{"potongan nol diabaikan", 150000, 0, 150000, 1},
+	}
+	for _, tt := range tests {
+		rincian := []PriceLineItem{{Kode: "harga_dasar", Jumlah: tt.total}}
+		rincian, total := applyDiskon(rincian, tt.total, diskonPesanan{Kode: "HEMAT", Potongan: tt.potongan})
+		if total != tt.want || len(rincian) != tt.rincian {
+			t.Errorf("%s: applyDiskon = %v dengan %d rincian, want %v dengan %d rincian",
+				tt.name, total, len(rincian), tt.want, tt.rincian)
+		}
+		if len(rincian) == 2 && (rincian[1].Kode != kodeRincianDiskon || rincian[1].Jumlah != total-tt.total) {
+			t.Errorf("%s: rincian diskon = %+v", tt.name, rincian[1])
+		}
+	}
+}
+
+func TestValidateTanggalPesanan(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	// 2024-05-15 pukul 00:30 WIB masih 2024-05-14 di UTC
+	now := time.Date(2024, 5, 15, 0, 30, 0, 0, jakarta)
+
+	tests := []struct {
+		tanggal string
+		valid   bool
+	}{
+		{"2024-05-14", false},
+		{"2024-05-15", true},
+		{"2024-05-16", true},
+	}
+	for _, tt := range tests {
+		errs := FieldErrors{}
+		waktu := parsePricingWaktu(errs, tt.tanggal, "")
+		validateTanggalPesanan(errs, waktu, now)
+		if (len(errs) == 0) != tt.valid {
+			t.Errorf("validateTanggalPesanan(%s) errors = %v, want valid %v", tt.tanggal, errs, tt.valid)
+		}
+	}
+}
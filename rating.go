@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+const (
+	ratingMin = 1
+	ratingMax = 5
+)
+
+// validateRating dipakai setiap kali testimoni ditulis agar ringkasan ulasan
+// hanya berisi rating 1 sampai 5.
+func validateRating(rating int) error {
+	if rating < ratingMin || rating > ratingMax {
+		return fmt.Errorf("rating harus antara %d dan %d", ratingMin, ratingMax)
+	}
+	return nil
+}
+
+// Rating pekerja adalah rata-rata rating semua testimoni untuk pesanan yang
+// dikerjakannya, atau 0 jika belum ada testimoni.
+const pekerjaRatingSubquery = `COALESCE((
+		SELECT AVG(t.rating)
+		FROM sijarta.testimoni t
+		JOIN sijarta.tr_pemesanan_jasa pj ON t.idtrpemesanan = pj.id
+		WHERE pj.idpekerja = p.Id AND t.rating BETWEEN 1 AND 5
+	), 0)`
+
+func recomputePekerjaRating(q sqlQuerier, pekerjaID string) error {
+	_, err := q.Exec(`UPDATE PEKERJA p SET Rating = `+pekerjaRatingSubquery+` WHERE p.Id = $1`, pekerjaID)
+	return err
+}
+
+// recomputeRatingForPemesanan menghitung ulang rating pekerja yang mengerjakan
+// pesanan pemesananID. Pesanan yang belum punya pekerja dilewati.
+func recomputeRatingForPemesanan(q sqlQuerier, pemesananID string) error {
+	var pekerjaID sql.NullString
+	err := q.QueryRow(`SELECT idpekerja FROM sijarta.tr_pemesanan_jasa WHERE id = $1`, pemesananID).Scan(&pekerjaID)
+	if err != nil {
+		return err
+	}
+	if !pekerjaID.Valid {
+		return nil
+	}
+	return recomputePekerjaRating(q, pekerjaID.String)
+}
+
+// backfillPekerjaRatings menghitung ulang rating semua pekerja dalam satu
+// statement dan mengembalikan jumlah pekerja yang diperbarui.
+func backfillPekerjaRatings(db *sql.DB) (int64, error) {
+	result, err := db.Exec(`UPDATE PEKERJA p SET Rating = ` + pekerjaRatingSubquery)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// commitTestimoni menyimpan perubahan testimoni lalu menginvalidasi cache
+// katalog, karena ringkasan ulasan dan rating pekerja ikut tampil di detail
+// subkategori.
+func commitTestimoni(tx *sql.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	catalogResponses.invalidate()
+	return nil
+}
+
+func UpdateTestimoni(db *sql.DB, userID, pemesananID, tgl, teks string, rating int) error {
+	// Validasi apakah user adalah pelanggan yang memesan jasa
+	isPemesan, err := IsPelangganPemesan(db, userID, pemesananID)
+	if err != nil {
+		return fmt.Errorf("gagal memvalidasi pelanggan: %v", err)
+	}
+	if !isPemesan {
+		return fmt.Errorf("anda bukan pelanggan yang memesan jasa ini, tidak dapat mengubah testimoni")
+	}
+
+	if err := validateRating(rating); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("gagal mengubah testimoni: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE sijarta.testimoni SET teks = $1, rating = $2
+	WHERE idtrpemesanan = $3 AND tgl = $4`, teks, rating, pemesananID, tgl)
+	if err != nil {
+		return fmt.Errorf("gagal mengubah testimoni: %v", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("testimoni tidak ditemukan")
+	}
+
+	if err := recomputeRatingForPemesanan(tx, pemesananID); err != nil {
+		return fmt.Errorf("gagal memperbarui rating pekerja: %v", err)
+	}
+
+	return commitTestimoni(tx)
+}
+
+func updateTestimoniHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type updateTestimoniReq struct {
+		UserID      string `json:"userId"`
+		PemesananID string `json:"pemesananId"`
+		Tgl         string `json:"tgl"`
+		Teks        string `json:"teks"`
+		Rating      int    `json:"rating"`
+	}
+
+	var req updateTestimoniReq
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.UserID = currentUserID(r)
+
+	err = UpdateTestimoni(db, req.UserID, req.PemesananID, req.Tgl, req.Teks, req.Rating)
+	if err != nil {
+		log.Printf("Error in UpdateTestimoni: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("Testimoni berhasil diubah"))
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestValidateRating(t *testing.T) {
+	tests := []struct {
+		rating int
+		valid  bool
+	}{
+		{-1, false},
+		{0, false},
+		{1, true},
+		{3, true},
+		{5, true},
+		{6, false},
+		{10, false},
+	}
+	for _, tt := range tests {
+		if err := validateRating(tt.rating); (err == nil) != tt.valid {
+			t.Errorf("validateRating(%d) = %v, want valid %v", tt.rating, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+)
+
+type Role string
+
+const (
+	RoleNone      Role = ""
+	RolePelanggan Role = "pelanggan"
+	RolePekerja   Role = "pekerja"
+	RoleAdmin     Role = "admin"
+)
+
+const roleContextKey contextKey = "role"
+
+// legacyLoginRole adalah nilai role numerik /login untuk frontend lama, yang
+// membaca 0 sebagai pekerja dan 1 sebagai pelanggan. Admin diberi nilai 2
+// agar tidak terbaca sebagai pekerja.
+func legacyLoginRole(role Role) int {
+	switch role {
+	case RolePelanggan:
+		return 1
+	case RoleAdmin:
+		return 2
+	}
+	return 0
+}
+
+// resolveRole menentukan role user dari tabel ADMIN, PEKERJA dan PELANGGAN.
+// Admin didahulukan karena akun admin juga bisa tercatat sebagai pelanggan.
+func resolveRole(db *sql.DB, userID string) (Role, error) {
+	var isAdmin, isPekerja, isPelanggan bool
+	err := db.QueryRow(`SELECT
+	EXISTS (SELECT 1 FROM ADMIN WHERE Id = $1),
+	EXISTS (SELECT 1 FROM PEKERJA WHERE Id = $1),
+	EXISTS (SELECT 1 FROM PELANGGAN WHERE Id = $1)`, userID).Scan(&isAdmin, &isPekerja, &isPelanggan)
+	if err != nil {
+		return RoleNone, err
+	}
+
+	switch {
+	case isAdmin:
+		return RoleAdmin, nil
+	case isPekerja:
+		return RolePekerja, nil
+	case isPelanggan:
+		return RolePelanggan, nil
+	}
+	return RoleNone, nil
+}
+
+// requireRoles hanya meneruskan request dari user dengan salah satu role yang
+// diizinkan. Harus dipasang di dalam authMiddleware.
+func requireRoles(next http.HandlerFunc, roles ...Role) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := currentRole(r)
+		for _, allowed := range roles {
+			if role == allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	}
+}
+
+func currentRole(r *http.Request) Role {
+	role, _ := r.Context().Value(roleContextKey).(Role)
+	return role
+}
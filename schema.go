@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Perubahan skema yang dibutuhkan fitur-fitur backend. Setiap statement harus
+// idempotent karena dijalankan ulang setiap kali server dinyalakan.
+var schemaStatements = []string{
+	// Hash bcrypt panjangnya 60 karakter
+	`ALTER TABLE "user" ALTER COLUMN Pwd TYPE VARCHAR(100)`,
+
+	`CREATE TABLE IF NOT EXISTS USER_SESSION (
+		Id UUID PRIMARY KEY,
+		UserId UUID NOT NULL REFERENCES "user"(Id) ON DELETE CASCADE,
+		RefreshTokenHash VARCHAR(64) NOT NULL UNIQUE,
+		PreviousTokenHash VARCHAR(64),
+		UserAgent TEXT NOT NULL DEFAULT '',
+		IpAddress VARCHAR(64) NOT NULL DEFAULT '',
+		CreatedAt TIMESTAMP NOT NULL,
+		LastUsedAt TIMESTAMP NOT NULL,
+		ExpiresAt TIMESTAMP NOT NULL,
+		RevokedAt TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS USER_SESSION_USERID_IDX ON USER_SESSION (UserId)`,
+	`CREATE INDEX IF NOT EXISTS USER_SESSION_PREVIOUS_IDX ON USER_SESSION (PreviousTokenHash)`,
+
+	`CREATE TABLE IF NOT EXISTS ADMIN (
+		Id UUID PRIMARY KEY REFERENCES "user"(Id) ON DELETE CASCADE
+	)`,
+
+	// Akun yang sudah ada sebelum kolom ini dibuat dianggap terverifikasi,
+	// sedangkan akun baru baru terverifikasi setelah memasukkan OTP
+	`ALTER TABLE "user" ADD COLUMN IF NOT EXISTS IsVerified BOOLEAN NOT NULL DEFAULT TRUE`,
+	`ALTER TABLE "user" ALTER COLUMN IsVerified SET DEFAULT FALSE`,
+	// Nomor HP baru yang menunggu konfirmasi OTP sebelum menggantikan NoHP
+	`ALTER TABLE "user" ADD COLUMN IF NOT EXISTS NoHPBaru VARCHAR(20)`,
+	`CREATE TABLE IF NOT EXISTS PHONE_OTP (
+		NoHP VARCHAR(20) NOT NULL,
+		Purpose VARCHAR(20) NOT NULL,
+		CodeHash VARCHAR(64) NOT NULL,
+		Attempts INT NOT NULL DEFAULT 0,
+		CreatedAt TIMESTAMP NOT NULL,
+		ExpiresAt TIMESTAMP NOT NULL,
+		PRIMARY KEY (NoHP, Purpose)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS LOGIN_ATTEMPT (
+		AttemptKey VARCHAR(100) PRIMARY KEY,
+		Failures INT NOT NULL DEFAULT 0,
+		LastFailureAt TIMESTAMP NOT NULL,
+		BlockedUntil TIMESTAMP
+	)`,
+
+	`ALTER TABLE "user" ADD COLUMN IF NOT EXISTS DeactivatedAt TIMESTAMP`,
+	`ALTER TABLE "user" ADD COLUMN IF NOT EXISTS ErasedAt TIMESTAMP`,
+
+	`ALTER TABLE PEKERJA ADD COLUMN IF NOT EXISTS LinkFotoThumbnail TEXT`,
+
+	`CREATE TABLE IF NOT EXISTS LEVEL_PELANGGAN (
+		Nama VARCHAR(50) PRIMARY KEY,
+		Urutan INT NOT NULL UNIQUE,
+		MinPesananSelesai INT NOT NULL DEFAULT 0,
+		MinTotalBelanja NUMERIC NOT NULL DEFAULT 0,
+		BisaBeliVoucher BOOLEAN NOT NULL DEFAULT TRUE,
+		DiskonVoucherPersen NUMERIC NOT NULL DEFAULT 0
+	)`,
+	// Tier bawaan, bisa diubah langsung di tabel tanpa mengubah kode
+	`INSERT INTO LEVEL_PELANGGAN (Nama, Urutan, MinPesananSelesai, MinTotalBelanja, BisaBeliVoucher, DiskonVoucherPersen) VALUES
+		('Basic', 1, 0, 0, TRUE, 0),
+		('Silver', 2, 5, 500000, TRUE, 5),
+		('Gold', 3, 15, 2000000, TRUE, 10)
+	ON CONFLICT (Nama) DO NOTHING`,
+	`CREATE TABLE IF NOT EXISTS PELANGGAN_LEVEL_HISTORY (
+		Id UUID PRIMARY KEY,
+		PelangganId UUID NOT NULL REFERENCES PELANGGAN(Id) ON DELETE CASCADE,
+		LevelLama VARCHAR(50) NOT NULL,
+		LevelBaru VARCHAR(50) NOT NULL,
+		PesananSelesai INT NOT NULL,
+		TotalBelanja NUMERIC NOT NULL,
+		Tgl TIMESTAMP NOT NULL
+	)`,
+
+	// HariKe mengikuti time.Weekday: 0 = Minggu, 6 = Sabtu
+	`CREATE TABLE IF NOT EXISTS PEKERJA_JAM_KERJA (
+		PekerjaId UUID NOT NULL REFERENCES PEKERJA(Id) ON DELETE CASCADE,
+		HariKe SMALLINT NOT NULL CHECK (HariKe BETWEEN 0 AND 6),
+		PRIMARY KEY (PekerjaId, HariKe)
+	)`,
+	// pesanan dikerjakan per hari, jadi jam mulai dan selesai tidak dipakai
+	`ALTER TABLE PEKERJA_JAM_KERJA DROP COLUMN IF EXISTS JamMulai`,
+	`ALTER TABLE PEKERJA_JAM_KERJA DROP COLUMN IF EXISTS JamSelesai`,
+	`CREATE TABLE IF NOT EXISTS PEKERJA_CUTI (
+		Id UUID PRIMARY KEY,
+		PekerjaId UUID NOT NULL REFERENCES PEKERJA(Id) ON DELETE CASCADE,
+		TglMulai DATE NOT NULL,
+		TglSelesai DATE NOT NULL CHECK (TglSelesai >= TglMulai),
+		Keterangan TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS PEKERJA_CUTI_PEKERJAID_IDX ON PEKERJA_CUTI (PekerjaId, TglSelesai)`,
+
+	`CREATE TABLE IF NOT EXISTS ALAMAT_USER (
+		Id UUID PRIMARY KEY,
+		UserId UUID NOT NULL REFERENCES "user"(Id) ON DELETE CASCADE,
+		Label VARCHAR(50) NOT NULL,
+		Jalan TEXT NOT NULL,
+		Kota VARCHAR(100) NOT NULL,
+		KodePos VARCHAR(5) NOT NULL,
+		Latitude DOUBLE PRECISION NOT NULL CHECK (Latitude BETWEEN -90 AND 90),
+		Longitude DOUBLE PRECISION NOT NULL CHECK (Longitude BETWEEN -180 AND 180),
+		IsDefault BOOLEAN NOT NULL DEFAULT FALSE,
+		CreatedAt TIMESTAMP NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS ALAMAT_USER_USERID_IDX ON ALAMAT_USER (UserId)`,
+	// Satu user paling banyak punya satu alamat default
+	`CREATE UNIQUE INDEX IF NOT EXISTS ALAMAT_USER_DEFAULT_IDX ON ALAMAT_USER (UserId) WHERE IsDefault`,
+	`ALTER TABLE IF EXISTS pesanan ADD COLUMN IF NOT EXISTS id_alamat UUID REFERENCES ALAMAT_USER(Id) ON DELETE SET NULL`,
+	// Alamat pilihan pelanggan dan koordinatnya disalin ke pesanan saat pesanan
+	// dibayar agar lokasi pekerjaan tidak ikut berubah ketika alamat diedit
+	// atau dihapus
+	`ALTER TABLE TR_PEMESANAN_JASA ADD COLUMN IF NOT EXISTS IdAlamat UUID REFERENCES ALAMAT_USER(Id) ON DELETE SET NULL`,
+	`ALTER TABLE TR_PEMESANAN_JASA ADD COLUMN IF NOT EXISTS Latitude DOUBLE PRECISION`,
+	`ALTER TABLE TR_PEMESANAN_JASA ADD COLUMN IF NOT EXISTS Longitude DOUBLE PRECISION`,
+
+	`ALTER TABLE PEKERJA ADD COLUMN IF NOT EXISTS RekeningStatus VARCHAR(20) NOT NULL DEFAULT 'unverified'`,
+	`ALTER TABLE PEKERJA ADD COLUMN IF NOT EXISTS RekeningCatatan TEXT`,
+	`ALTER TABLE PEKERJA ADD COLUMN IF NOT EXISTS RekeningVerifiedAt TIMESTAMP`,
+	// Kategori pencairan saldo ke rekening bank hanya boleh dipakai oleh
+	// /mypay/withdraw. Kategori lama ditandai sekali berdasarkan namanya saat
+	// kolom dibuat, selanjutnya bisa diubah langsung di tabel.
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'kategori_tr_mypay' AND column_name = 'ispencairan') THEN
+			ALTER TABLE KATEGORI_TR_MYPAY ADD COLUMN IsPencairan BOOLEAN NOT NULL DEFAULT FALSE;
+			UPDATE KATEGORI_TR_MYPAY SET IsPencairan = TRUE
+			WHERE Nama ILIKE '%withdraw%' OR Nama ILIKE '%penarikan%' OR Nama ILIKE '%pencairan%';
+		END IF;
+	END $$`,
+
+	// Katalog diarsipkan, bukan dihapus, agar pesanan lama tetap valid
+	`ALTER TABLE KATEGORI_JASA ADD COLUMN IF NOT EXISTS Urutan INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE KATEGORI_JASA ADD COLUMN IF NOT EXISTS ArchivedAt TIMESTAMP`,
+	`ALTER TABLE SUBKATEGORI_JASA ADD COLUMN IF NOT EXISTS Deskripsi TEXT`,
+	`ALTER TABLE SUBKATEGORI_JASA ADD COLUMN IF NOT EXISTS Urutan INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE SUBKATEGORI_JASA ADD COLUMN IF NOT EXISTS ArchivedAt TIMESTAMP`,
+	`CREATE TABLE IF NOT EXISTS sesi_layanan (
+		id SERIAL PRIMARY KEY,
+		id_subkategori UUID NOT NULL REFERENCES SUBKATEGORI_JASA(Id),
+		nama_sesi VARCHAR(100) NOT NULL,
+		harga NUMERIC NOT NULL CHECK (harga > 0)
+	)`,
+	`ALTER TABLE sesi_layanan ADD COLUMN IF NOT EXISTS urutan INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE sesi_layanan ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP`,
+
+	// Aturan harga dinamis. Pengali berlaku untuk akhir_pekan, hari_libur dan
+	// jam_sibuk; HargaMinimum untuk minimum_kategori. KategoriJasaId kosong
+	// berarti aturan berlaku untuk semua kategori.
+	`CREATE TABLE IF NOT EXISTS ATURAN_HARGA (
+		Kode VARCHAR(50) PRIMARY KEY,
+		Jenis VARCHAR(20) NOT NULL CHECK (Jenis IN ('akhir_pekan', 'hari_libur', 'jam_sibuk', 'minimum_kategori')),
+		Keterangan VARCHAR(100) NOT NULL,
+		KategoriJasaId UUID REFERENCES KATEGORI_JASA(Id),
+		Pengali NUMERIC NOT NULL DEFAULT 1 CHECK (Pengali > 0),
+		HargaMinimum NUMERIC CHECK (HargaMinimum > 0),
+		JamMulai TIME,
+		JamSelesai TIME,
+		Urutan INT NOT NULL DEFAULT 0,
+		Aktif BOOLEAN NOT NULL DEFAULT TRUE,
+		CHECK (Jenis <> 'jam_sibuk' OR (JamMulai IS NOT NULL AND JamSelesai IS NOT NULL)),
+		CHECK (Jenis <> 'minimum_kategori' OR (HargaMinimum IS NOT NULL AND KategoriJasaId IS NOT NULL))
+	)`,
+	// Aturan bawaan belum aktif agar harga tidak berubah sampai diaktifkan
+	// langsung di tabel
+	`INSERT INTO ATURAN_HARGA (Kode, Jenis, Keterangan, Pengali, JamMulai, JamSelesai, Urutan, Aktif) VALUES
+		('akhir_pekan', 'akhir_pekan', 'Tambahan biaya akhir pekan', 1.1, NULL, NULL, 1, FALSE),
+		('hari_libur', 'hari_libur', 'Tambahan biaya hari libur', 1.25, NULL, NULL, 2, FALSE),
+		('jam_sibuk_sore', 'jam_sibuk', 'Tambahan biaya jam sibuk', 1.15, '17:00', '20:00', 3, FALSE)
+	ON CONFLICT (Kode) DO NOTHING`,
+	`CREATE TABLE IF NOT EXISTS HARI_LIBUR (
+		Tgl DATE PRIMARY KEY,
+		Nama VARCHAR(100) NOT NULL
+	)`,
+
+	// Pencarian katalog: stemmer bahasa Indonesia jika tersedia (PostgreSQL
+	// 13 ke atas), selain itu konfigurasi simple tanpa stemming
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'katalog_id') THEN
+			IF EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'indonesian') THEN
+				CREATE TEXT SEARCH CONFIGURATION katalog_id (COPY = indonesian);
+			ELSE
+				CREATE TEXT SEARCH CONFIGURATION katalog_id (COPY = simple);
+			END IF;
+		END IF;
+	END $$`,
+}
+
+// trigramStatements membuat index untuk pencarian trigram. Berbeda dengan
+// schemaStatements, kegagalannya tidak menghentikan server.
+var trigramStatements = []string{
+	`CREATE INDEX IF NOT EXISTS SUBKATEGORI_JASA_NAMA_TRGM_IDX ON SUBKATEGORI_JASA USING gin (NamaSubkategori gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS SUBKATEGORI_JASA_DESKRIPSI_TRGM_IDX ON SUBKATEGORI_JASA USING gin (Deskripsi gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS KATEGORI_JASA_NAMA_TRGM_IDX ON KATEGORI_JASA USING gin (NamaKategori gin_trgm_ops)`,
+}
+
+// sqlQuerier dipenuhi oleh *sql.DB dan *sql.Tx sehingga helper query bisa
+// dipakai di dalam maupun di luar transaksi.
+type sqlQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func migrateSchema(db *sql.DB) error {
+	for _, statement := range schemaStatements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("gagal menjalankan migrasi skema: %v", err)
+		}
+	}
+	return nil
+}
+
+// setupTrigramSearch menyiapkan extension pg_trgm beserta index-nya dan
+// melaporkan apakah pencarian trigram bisa dipakai. Role database belum tentu
+// boleh membuat extension, sehingga kegagalan hanya dicatat dan pencarian
+// kembali memakai ILIKE. Extension juga bisa dipasang terpisah oleh DBA.
+func setupTrigramSearch(db *sql.DB) bool {
+	var installed bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')`).Scan(&installed)
+	if err == nil && !installed {
+		_, err = db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`)
+	}
+	for _, statement := range trigramStatements {
+		if err != nil {
+			break
+		}
+		_, err = db.Exec(statement)
+	}
+	if err != nil {
+		log.Printf("pg_trgm is not available, catalog search falls back to ILIKE: %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 50
+	searchMaxQueryLen  = 100
+	// Kemiripan trigram minimum agar salah ketik seperti "tukang ledng" atau
+	// "pembersihn" tetap cocok dengan "tukang ledeng" dan "pembersihan".
+	searchSimilarityThreshold = 0.4
+)
+
+// searchTrigram diisi saat server dinyalakan. Tanpa pg_trgm pencarian hanya
+// memakai full-text search dan ILIKE, tanpa toleransi salah ketik.
+var searchTrigram bool
+
+type SearchResult struct {
+	SubkategoriId string         `json:"subkategoriId"`
+	Nama          string         `json:"nama"`
+	Deskripsi     string         `json:"deskripsi"`
+	KategoriId    string         `json:"kategoriId"`
+	NamaKategori  string         `json:"namaKategori"`
+	Skor          float64        `json:"skor"`
+	HargaMulai    *float64       `json:"hargaMulai"`
+	Sesi          []HomepageSesi `json:"sesi"`
+}
+
+type SearchResponseBody struct {
+	Status  bool           `json:"status"`
+	Message string         `json:"message"`
+	Errors  FieldErrors    `json:"errors,omitempty"`
+	Hasil   []SearchResult `json:"hasil"`
+}
+
+type searchParams struct {
+	Query    string
+	MinHarga sql.NullFloat64
+	MaxHarga sql.NullFloat64
+	Limit    int
+}
+
+func parseSearchHarga(errs FieldErrors, field, raw string) sql.NullFloat64 {
+	if raw == "" {
+		return sql.NullFloat64{}
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value < 0 {
+		errs.add(field, "Harga harus berupa angka positif")
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: value, Valid: true}
+}
+
+func parseSearchParams(r *http.Request) (searchParams, FieldErrors) {
+	values := r.URL.Query()
+	errs := FieldErrors{}
+	params := searchParams{
+		Query: strings.TrimSpace(values.Get("q")),
+		Limit: searchDefaultLimit,
+	}
+
+	validateRequired(errs, "q", params.Query, "Kata kunci wajib diisi")
+	if len(params.Query) > searchMaxQueryLen {
+		errs.add("q", "Kata kunci maksimal 100 karakter")
+	}
+
+	params.MinHarga = parseSearchHarga(errs, "minHarga", values.Get("minHarga"))
+	params.MaxHarga = parseSearchHarga(errs, "maxHarga", values.Get("maxHarga"))
+	if params.MinHarga.Valid && params.MaxHarga.Valid && params.MinHarga.Float64 > params.MaxHarga.Float64 {
+		errs.add("maxHarga", "Harga maksimum tidak boleh kurang dari harga minimum")
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > searchMaxLimit {
+			errs.add("limit", "Limit harus 1 sampai 50")
+		} else {
+			params.Limit = limit
+		}
+	}
+	return params, errs
+}
+
+// escapeLike meloloskan karakter wildcard agar kata kunci dicocokkan apa
+// adanya oleh LIKE/ILIKE.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// searchCatalog mencari subkategori dengan full-text search (konfigurasi
+// katalog_id), ILIKE, dan jika pg_trgm tersedia juga kemiripan trigram untuk
+// toleransi salah ketik. Subkategori hanya masuk hasil jika punya sesi aktif
+// dalam rentang harga. q harus berupa transaksi karena ambang kemiripan
+// diatur dengan set_config yang hanya berlaku sampai transaksi selesai.
+func searchCatalog(q sqlQuerier, params searchParams) ([]SearchResult, error) {
+	// Operator <% memakai index GIN trigram, berbeda dengan memanggil
+	// word_similarity() secara langsung
+	var matchTrigram, skorTrigram string
+	if searchTrigram {
+		_, err := q.Exec(`SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`,
+			strconv.FormatFloat(searchSimilarityThreshold, 'f', -1, 64))
+		if err != nil {
+			return nil, err
+		}
+		matchTrigram = `
+			OR $1 <% s.NamaSubkategori
+			OR $1 <% k.NamaKategori
+			OR $1 <% s.Deskripsi`
+		skorTrigram = `
+			+ GREATEST(word_similarity($1, s.NamaSubkategori), word_similarity($1, k.NamaKategori))`
+	}
+
+	rows, err := q.Query(`
+		SELECT s.Id, s.NamaSubkategori, COALESCE(s.Deskripsi, ''), k.Id, k.NamaKategori,
+		ts_rank(d.doc, websearch_to_tsquery('katalog_id', $1))`+skorTrigram+` AS skor
+		FROM SUBKATEGORI_JASA s
+		JOIN KATEGORI_JASA k ON k.Id = s.KategoriJasaId
+		CROSS JOIN LATERAL (
+			SELECT setweight(to_tsvector('katalog_id', s.NamaSubkategori), 'A')
+				|| setweight(to_tsvector('katalog_id', k.NamaKategori), 'B')
+				|| setweight(to_tsvector('katalog_id', COALESCE(s.Deskripsi, '')), 'C') AS doc
+		) d
+		WHERE k.ArchivedAt IS NULL AND s.ArchivedAt IS NULL
+		AND (
+			d.doc @@ websearch_to_tsquery('katalog_id', $1)
+			OR s.NamaSubkategori ILIKE $2
+			OR k.NamaKategori ILIKE $2
+			OR s.Deskripsi ILIKE $2`+matchTrigram+`
+		)
+		AND EXISTS (
+			SELECT 1 FROM sesi_layanan sesi
+			WHERE sesi.id_subkategori = s.Id AND sesi.archived_at IS NULL
+			AND ($3::numeric IS NULL OR sesi.harga >= $3)
+			AND ($4::numeric IS NULL OR sesi.harga <= $4)
+		)
+		ORDER BY skor DESC, s.NamaSubkategori
+		LIMIT $5`,
+		params.Query, "%"+escapeLike(params.Query)+"%", params.MinHarga, params.MaxHarga, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	index := map[string]int{}
+	var ids []string
+	for rows.Next() {
+		result := SearchResult{Sesi: []HomepageSesi{}}
+		err := rows.Scan(&result.SubkategoriId, &result.Nama, &result.Deskripsi, &result.KategoriId, &result.NamaKategori, &result.Skor)
+		if err != nil {
+			return nil, err
+		}
+		index[result.SubkategoriId] = len(results)
+		ids = append(ids, result.SubkategoriId)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	// Sesi semua hasil diambil sekaligus agar app cukup satu kali request
+	sesiRows, err := q.Query(`SELECT sesi.id_subkategori::text, sesi.id, sesi.nama_sesi, sesi.harga
+	FROM sesi_layanan sesi
+	WHERE sesi.id_subkategori::text = ANY($1) AND sesi.archived_at IS NULL
+	AND ($2::numeric IS NULL OR sesi.harga >= $2)
+	AND ($3::numeric IS NULL OR sesi.harga <= $3)
+	ORDER BY sesi.urutan, sesi.harga, sesi.id`, pq.Array(ids), params.MinHarga, params.MaxHarga)
+	if err != nil {
+		return nil, err
+	}
+	defer sesiRows.Close()
+
+	for sesiRows.Next() {
+		var subID string
+		var sesi HomepageSesi
+		if err := sesiRows.Scan(&subID, &sesi.Id, &sesi.Nama, &sesi.Harga); err != nil {
+			return nil, err
+		}
+		result := &results[index[subID]]
+		result.Sesi = append(result.Sesi, sesi)
+		if result.HargaMulai == nil || sesi.Harga < *result.HargaMulai {
+			hargaMulai := sesi.Harga
+			result.HargaMulai = &hargaMulai
+		}
+	}
+	return results, sesiRows.Err()
+}
+
+func searchLayanan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params, errs := parseSearchParams(r)
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(&SearchResponseBody{
+			Status:  false,
+			Message: "Parameter pencarian tidak valid",
+			Errors:  errs,
+			Hasil:   []SearchResult{},
+		})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&SearchResponseBody{
+			Status:  false,
+			Message: err.Error(),
+			Hasil:   []SearchResult{},
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	results, err := searchCatalog(tx, params)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&SearchResponseBody{
+			Status:  false,
+			Message: err.Error(),
+			Hasil:   []SearchResult{},
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(&SearchResponseBody{
+		Status:  true,
+		Message: "Berhasil mendapatkan data",
+		Hasil:   results,
+	})
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+var errInvalidRefreshToken = errors.New("refresh token tidak valid")
+
+type RefreshRequestBody struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type TokenResponseBody struct {
+	Status       bool       `json:"status"`
+	Message      string     `json:"message"`
+	AccessToken  string     `json:"accessToken,omitempty"`
+	RefreshToken string     `json:"refreshToken,omitempty"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+}
+
+type SessionItem struct {
+	Id         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IpAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+type GetSessionsResponseBody struct {
+	Status   bool          `json:"status"`
+	Message  string        `json:"message"`
+	Sessions []SessionItem `json:"sessions"`
+}
+
+type RevokeSessionRequestBody struct {
+	SessionId string `json:"sessionId"`
+}
+
+type RevokeSessionResponseBody struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// createSession menyimpan sesi baru untuk userID dan mengembalikan id sesi
+// beserta refresh token dalam bentuk asli. Hanya hash token yang disimpan.
+func createSession(db *sql.DB, userID string, r *http.Request) (string, string, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	sessionID := uuid.New().String()
+	now := time.Now()
+	_, err = db.Exec(`INSERT INTO USER_SESSION
+	(Id, UserId, RefreshTokenHash, UserAgent, IpAddress, CreatedAt, LastUsedAt, ExpiresAt)
+	VALUES ($1, $2, $3, $4, $5, $6, $6, $7)`,
+		sessionID, userID, hashRefreshToken(refreshToken), r.UserAgent(), clientIP(r), now, now.Add(refreshTokenTTL))
+	if err != nil {
+		return "", "", err
+	}
+
+	return sessionID, refreshToken, nil
+}
+
+// rotateSession menukar refresh token lama dengan yang baru. Token lama yang
+// dipakai ulang setelah dirotasi dianggap bocor sehingga sesinya dicabut.
+func rotateSession(db *sql.DB, refreshToken string) (string, string, string, error) {
+	tokenHash := hashRefreshToken(refreshToken)
+
+	var sessionID, userID string
+	err := db.QueryRow(`SELECT Id, UserId FROM USER_SESSION
+	WHERE PreviousTokenHash = $1 AND RevokedAt IS NULL`, tokenHash).Scan(&sessionID, &userID)
+	if err == nil {
+		if err := revokeSession(db, sessionID); err != nil {
+			return "", "", "", err
+		}
+		return "", "", "", errInvalidRefreshToken
+	} else if err != sql.ErrNoRows {
+		return "", "", "", err
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	now := time.Now()
+	err = db.QueryRow(`UPDATE USER_SESSION SET
+	PreviousTokenHash = RefreshTokenHash,
+	RefreshTokenHash = $1,
+	LastUsedAt = $2,
+	ExpiresAt = $3
+	WHERE RefreshTokenHash = $4 AND RevokedAt IS NULL AND ExpiresAt > $2
+	RETURNING Id, UserId`,
+		hashRefreshToken(newToken), now, now.Add(refreshTokenTTL), tokenHash).Scan(&sessionID, &userID)
+	if err == sql.ErrNoRows {
+		return "", "", "", errInvalidRefreshToken
+	} else if err != nil {
+		return "", "", "", err
+	}
+
+	return sessionID, userID, newToken, nil
+}
+
+func isSessionActive(db *sql.DB, sessionID string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM USER_SESSION
+	WHERE Id = $1 AND RevokedAt IS NULL AND ExpiresAt > NOW()`, sessionID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func revokeSession(db *sql.DB, sessionID string) error {
+	_, err := db.Exec(`UPDATE USER_SESSION SET RevokedAt = NOW() WHERE Id = $1 AND RevokedAt IS NULL`, sessionID)
+	return err
+}
+
+func revokeAllSessions(q sqlQuerier, userID string) error {
+	_, err := q.Exec(`UPDATE USER_SESSION SET RevokedAt = NOW() WHERE UserId = $1 AND RevokedAt IS NULL`, userID)
+	return err
+}
+
+func revokeOtherSessions(db *sql.DB, userID, keepSessionID string) error {
+	_, err := db.Exec(`UPDATE USER_SESSION SET RevokedAt = NOW()
+	WHERE UserId = $1 AND Id <> $2 AND RevokedAt IS NULL`, userID, keepSessionID)
+	return err
+}
+
+func refreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body RefreshRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.RefreshToken == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sessionID, userID, refreshToken, err := rotateSession(db, body.RefreshToken)
+	if err == errInvalidRefreshToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(&TokenResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, expiresAt, err := issueAccessToken(userID, sessionID)
+	if err != nil {
+		http.Error(w, "Failed to issue access token", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&TokenResponseBody{
+		Status:       true,
+		Message:      "Success",
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    &expiresAt,
+	})
+}
+
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := revokeSession(db, currentSessionID(r)); err != nil {
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&RevokeSessionResponseBody{
+		Status:  true,
+		Message: "Berhasil logout",
+	})
+}
+
+func getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := db.Query(`SELECT Id, UserAgent, IpAddress, CreatedAt, LastUsedAt, ExpiresAt
+	FROM USER_SESSION
+	WHERE UserId = $1 AND RevokedAt IS NULL AND ExpiresAt > NOW()
+	ORDER BY LastUsedAt DESC`, currentUserID(r))
+	if err != nil {
+		json.NewEncoder(w).Encode(&GetSessionsResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+
+	sessions := []SessionItem{}
+	for rows.Next() {
+		var session SessionItem
+		err := rows.Scan(
+			&session.Id,
+			&session.UserAgent,
+			&session.IpAddress,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt)
+		if err != nil {
+			json.NewEncoder(w).Encode(&GetSessionsResponseBody{
+				Status:  false,
+				Message: err.Error(),
+			})
+			return
+		}
+		session.Current = session.Id == currentSessionID(r)
+		sessions = append(sessions, session)
+	}
+
+	json.NewEncoder(w).Encode(&GetSessionsResponseBody{
+		Status:   true,
+		Message:  "Berhasil mendapatkan data",
+		Sessions: sessions,
+	})
+}
+
+func revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body RevokeSessionRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec(`UPDATE USER_SESSION SET RevokedAt = NOW()
+	WHERE Id = $1 AND UserId = $2 AND RevokedAt IS NULL`, body.SessionId, currentUserID(r))
+	if err != nil {
+		json.NewEncoder(w).Encode(&RevokeSessionResponseBody{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		json.NewEncoder(w).Encode(&RevokeSessionResponseBody{
+			Status:  false,
+			Message: "Sesi tidak ditemukan",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(&RevokeSessionResponseBody{
+		Status:  true,
+		Message: "Sesi berhasil dicabut",
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// SMSSender mengirim pesan teks ke nomor HP. Implementasi produksi cukup
+// memenuhi interface ini lalu dipilih di newSMSSender.
+type SMSSender interface {
+	Send(noHP, message string) error
+}
+
+// logSMSSender hanya menulis pesan ke log, untuk development lokal.
+type logSMSSender struct{}
+
+func (logSMSSender) Send(noHP, message string) error {
+	log.Printf("SMS to %s: %s", noHP, message)
+	return nil
+}
+
+// fileSMSSender menambahkan setiap pesan ke sebuah file outbox.
+type fileSMSSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *fileSMSSender) Send(noHP, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), noHP, message)
+	return err
+}
+
+// newSMSSender memilih implementasi berdasarkan SMS_SENDER ("log" atau
+// "file"). Path outbox diambil dari SMS_OUTBOX_PATH.
+func newSMSSender() SMSSender {
+	switch os.Getenv("SMS_SENDER") {
+	case "file":
+		path := os.Getenv("SMS_OUTBOX_PATH")
+		if path == "" {
+			path = "sms_outbox.log"
+		}
+		return &fileSMSSender{path: path}
+	default:
+		return logSMSSender{}
+	}
+}
+
+var smsSender = newSMSSender()
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStorage menyimpan file unggahan dan memberikan URL publiknya. Nama yang
+// diberikan pemanggil sudah unik, sehingga Save boleh menimpa file yang ada.
+// Delete tidak menganggap file yang sudah tidak ada sebagai kesalahan.
+type FileStorage interface {
+	Save(name string, data []byte) error
+	Delete(name string) error
+	URL(name string) string
+}
+
+// localFileStorage menulis file ke direktori lokal yang disajikan oleh
+// uploadsHandler di bawah path /uploads/.
+type localFileStorage struct {
+	dir     string
+	baseURL string
+}
+
+func (s *localFileStorage) Save(name string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, filepath.Base(name)), data, 0644)
+}
+
+func (s *localFileStorage) Delete(name string) error {
+	err := os.Remove(filepath.Join(s.dir, filepath.Base(name)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *localFileStorage) URL(name string) string {
+	return strings.TrimSuffix(s.baseURL, "/") + "/uploads/" + filepath.Base(name)
+}
+
+func newFileStorage() *localFileStorage {
+	dir := os.Getenv("UPLOAD_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+
+	baseURL := os.Getenv("PUBLIC_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	return &localFileStorage{dir: dir, baseURL: baseURL}
+}
+
+var localStorage = newFileStorage()
+var fileStorage FileStorage = localStorage
+
+// uploadsHandler menyajikan file yang disimpan localFileStorage tanpa
+// menampilkan daftar isi direktori.
+func uploadsHandler() http.Handler {
+	files := http.StripPrefix("/uploads/", http.FileServer(http.Dir(localStorage.dir)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		files.ServeHTTP(w, r)
+	})
+}
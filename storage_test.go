@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalFileStorageDelete(t *testing.T) {
+	s := &localFileStorage{dir: t.TempDir()}
+	if err := s.Save("user-foto.jpg", []byte("foto")); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if err := s.Delete("user-foto.jpg"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, "user-foto.jpg")); !os.IsNotExist(err) {
+		t.Errorf("file masih ada setelah Delete(), Stat() error = %v", err)
+	}
+
+	// File yang sudah terhapus tidak dianggap kesalahan
+	if err := s.Delete("user-foto.jpg"); err != nil {
+		t.Errorf("Delete() pada file yang tidak ada error = %v", err)
+	}
+}
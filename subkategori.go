@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+)
+
+type SubkategoriPekerja struct {
+	Id                string  `json:"id"`
+	Nama              string  `json:"name"`
+	LinkFoto          string  `json:"link"`
+	LinkFotoThumbnail string  `json:"thumbnail"`
+	Rating            float64 `json:"rating"`
+}
+
+// UlasanSummary merangkum testimoni satu subkategori. Histogram selalu berisi
+// kunci 1 sampai 5 walaupun jumlahnya 0, dan rating lama di luar rentang itu
+// tidak dihitung.
+type UlasanSummary struct {
+	RataRata  float64     `json:"rataRata"`
+	Jumlah    int         `json:"jumlah"`
+	Histogram map[int]int `json:"histogram"`
+}
+
+type SubkategoriDetailResponseBody struct {
+	Status       bool                 `json:"status"`
+	Message      string               `json:"message"`
+	Id           string               `json:"id,omitempty"`
+	Nama         string               `json:"nama,omitempty"`
+	Deskripsi    string               `json:"deskripsi"`
+	KategoriId   string               `json:"kategoriId,omitempty"`
+	NamaKategori string               `json:"namaKategori,omitempty"`
+	HargaMulai   *float64             `json:"hargaMulai"`
+	Sesi         []HomepageSesi       `json:"sesi"`
+	Pekerja      []SubkategoriPekerja `json:"pekerja"`
+	Ulasan       UlasanSummary        `json:"ulasan"`
+}
+
+func loadSubkategoriSesi(q sqlQuerier, subkategoriID string) ([]HomepageSesi, error) {
+	rows, err := q.Query(`SELECT id, nama_sesi, harga
+	FROM sesi_layanan
+	WHERE id_subkategori = $1 AND archived_at IS NULL
+	ORDER BY urutan, harga, id`, subkategoriID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sesi := []HomepageSesi{}
+	for rows.Next() {
+		var item HomepageSesi
+		if err := rows.Scan(&item.Id, &item.Nama, &item.Harga); err != nil {
+			return nil, err
+		}
+		sesi = append(sesi, item)
+	}
+	return sesi, rows.Err()
+}
+
+// loadKategoriPekerja mengambil pekerja aktif yang terdaftar di kategori
+// induk, diurutkan dari rating tertinggi.
+func loadKategoriPekerja(q sqlQuerier, kategoriID string) ([]SubkategoriPekerja, error) {
+	rows, err := q.Query(`SELECT u.Id, u.Nama, p.LinkFoto, COALESCE(p.LinkFotoThumbnail, ''), p.Rating
+	FROM PEKERJA_KATEGORI_JASA pk
+	JOIN PEKERJA p ON p.Id = pk.PekerjaId
+	JOIN "user" u ON u.Id = p.Id
+	WHERE pk.KategoriJasaId = $1 AND u.DeactivatedAt IS NULL
+	ORDER BY p.Rating DESC, u.Nama, u.Id`, kategoriID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pekerja := []SubkategoriPekerja{}
+	for rows.Next() {
+		var item SubkategoriPekerja
+		if err := rows.Scan(&item.Id, &item.Nama, &item.LinkFoto, &item.LinkFotoThumbnail, &item.Rating); err != nil {
+			return nil, err
+		}
+		pekerja = append(pekerja, item)
+	}
+	return pekerja, rows.Err()
+}
+
+func loadUlasanSummary(q sqlQuerier, subkategoriID string) (UlasanSummary, error) {
+	summary := UlasanSummary{Histogram: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	rows, err := q.Query(`SELECT t.rating, COUNT(*)
+	FROM sijarta.testimoni t
+	JOIN sijarta.tr_pemesanan_jasa pj ON t.idtrpemesanan = pj.id
+	WHERE pj.idkategorijasa = $1 AND t.rating BETWEEN $2 AND $3
+	GROUP BY t.rating`, subkategoriID, ratingMin, ratingMax)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	var total int
+	for rows.Next() {
+		var rating, jumlah int
+		if err := rows.Scan(&rating, &jumlah); err != nil {
+			return summary, err
+		}
+		summary.Histogram[rating] += jumlah
+		summary.Jumlah += jumlah
+		total += rating * jumlah
+	}
+	if summary.Jumlah > 0 {
+		summary.RataRata = float64(total) / float64(summary.Jumlah)
+	}
+	return summary, rows.Err()
+}
+
+// loadSubkategoriDetail menghasilkan sql.ErrNoRows jika subkategori tidak ada
+// atau subkategori maupun kategorinya sudah diarsipkan.
+func loadSubkategoriDetail(q sqlQuerier, id string) (*SubkategoriDetailResponseBody, error) {
+	detail := &SubkategoriDetailResponseBody{}
+	err := q.QueryRow(`SELECT s.Id, s.NamaSubkategori, COALESCE(s.Deskripsi, ''), k.Id, k.NamaKategori
+	FROM SUBKATEGORI_JASA s
+	JOIN KATEGORI_JASA k ON k.Id = s.KategoriJasaId
+	WHERE s.Id::text = $1 AND s.ArchivedAt IS NULL AND k.ArchivedAt IS NULL`, id).Scan(
+		&detail.Id, &detail.Nama, &detail.Deskripsi, &detail.KategoriId, &detail.NamaKategori)
+	if err != nil {
+		return nil, err
+	}
+
+	if detail.Sesi, err = loadSubkategoriSesi(q, detail.Id); err != nil {
+		return nil, err
+	}
+	for _, sesi := range detail.Sesi {
+		if detail.HargaMulai == nil || sesi.Harga < *detail.HargaMulai {
+			hargaMulai := sesi.Harga
+			detail.HargaMulai = &hargaMulai
+		}
+	}
+
+	if detail.Pekerja, err = loadKategoriPekerja(q, detail.KategoriId); err != nil {
+		return nil, err
+	}
+	if detail.Ulasan, err = loadUlasanSummary(q, detail.Id); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// getSubkategoriDetail melengkapi /subkategori yang tetap mengembalikan array
+// datar untuk client lama. Response ikut di-cache bersama katalog, sehingga
+// perubahan pekerja dan testimoni juga harus menginvalidasi cache.
+func getSubkategoriDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	err := serveCatalogJSON(w, r, "subkategori-detail:"+id, func() ([]byte, error) {
+		detail, err := loadSubkategoriDetail(db, id)
+		if err != nil {
+			return nil, err
+		}
+		detail.Status = true
+		detail.Message = "Berhasil mendapatkan data"
+		return json.Marshal(detail)
+	})
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(&SubkategoriDetailResponseBody{
+			Status:  false,
+			Message: "Subkategori tidak ditemukan",
+			Sesi:    []HomepageSesi{},
+			Pekerja: []SubkategoriPekerja{},
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&SubkategoriDetailResponseBody{
+			Status:  false,
+			Message: err.Error(),
+			Sesi:    []HomepageSesi{},
+			Pekerja: []SubkategoriPekerja{},
+		})
+	}
+}
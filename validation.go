@@ -0,0 +1,63 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	noHPPattern          = regexp.MustCompile(`^(\+62|62|0)8[1-9][0-9]{6,11}$`)
+	npwpPattern          = regexp.MustCompile(`^(\d{2}\.\d{3}\.\d{3}\.\d-\d{3}\.\d{3}|\d{15}|\d{16})$`)
+	nomorRekeningPattern = regexp.MustCompile(`^[0-9]{6,20}$`)
+)
+
+// FieldErrors memetakan nama field JSON ke pesan kesalahannya agar form di
+// frontend bisa menandai field yang salah.
+type FieldErrors map[string]string
+
+func (e FieldErrors) add(field, message string) {
+	if _, exists := e[field]; !exists {
+		e[field] = message
+	}
+}
+
+func validateNoHP(errs FieldErrors, field, noHP string) {
+	if !noHPPattern.MatchString(noHP) {
+		errs.add(field, "Nomor HP harus diawali 08, 628 atau +628 dan terdiri dari 9-14 digit")
+	}
+}
+
+// noHPTaken memeriksa apakah nomor HP sudah dipakai user selain
+// exceptUserID. exceptUserID kosong berarti semua user diperiksa.
+func noHPTaken(q sqlQuerier, noHP, exceptUserID string) (bool, error) {
+	var taken bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM "user" WHERE NoHP = $1 AND Id::text <> $2)`, noHP, exceptUserID).Scan(&taken)
+	return taken, err
+}
+
+func validateTglLahir(errs FieldErrors, field string, tglLahir time.Time) {
+	if tglLahir.IsZero() {
+		errs.add(field, "Tanggal lahir wajib diisi")
+	} else if !tglLahir.Before(time.Now()) {
+		errs.add(field, "Tanggal lahir harus di masa lalu")
+	}
+}
+
+func validateRequired(errs FieldErrors, field, value, message string) {
+	if strings.TrimSpace(value) == "" {
+		errs.add(field, message)
+	}
+}
+
+func validateNPWP(errs FieldErrors, field, npwp string) {
+	if !npwpPattern.MatchString(npwp) {
+		errs.add(field, "NPWP harus berformat 99.999.999.9-999.999 atau 15-16 digit angka")
+	}
+}
+
+func validateNomorRekening(errs FieldErrors, field, nomorRekening string) {
+	if !nomorRekeningPattern.MatchString(nomorRekening) {
+		errs.add(field, "Nomor rekening harus 6-20 digit angka")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateNoHP(t *testing.T) {
+	tests := []struct {
+		noHP  string
+		valid bool
+	}{
+		{"081234567890", true},
+		{"6281234567890", true},
+		{"+6281234567890", true},
+		{"0812345678", true},
+		{"08123456789012", true},
+		{"081234567", true},
+		{"08123456", false},
+		{"081234567890123", false},
+		{"080123456789", false},
+		{"021234567890", false},
+		{"0812-3456-7890", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		errs := FieldErrors{}
+		validateNoHP(errs, "number", tt.noHP)
+		if _, invalid := errs["number"]; invalid == tt.valid {
+			t.Errorf("validateNoHP(%q) valid = %v, want %v", tt.noHP, !invalid, tt.valid)
+		}
+	}
+}
+
+func TestValidateNPWP(t *testing.T) {
+	tests := []struct {
+		npwp  string
+		valid bool
+	}{
+		{"12.345.678.9-012.345", true},
+		{"123456789012345", true},
+		{"1234567890123456", true},
+		{"12.345.678.9-012345", false},
+		{"12345678901234", false},
+		{"12345678901234567", false},
+		{"12.345.678.9-012.34a", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		errs := FieldErrors{}
+		validateNPWP(errs, "npwp", tt.npwp)
+		if _, invalid := errs["npwp"]; invalid == tt.valid {
+			t.Errorf("validateNPWP(%q) valid = %v, want %v", tt.npwp, !invalid, tt.valid)
+		}
+	}
+}
+
+func TestValidateNomorRekening(t *testing.T) {
+	tests := []struct {
+		nomorRekening string
+		valid         bool
+	}{
+		{"123456", true},
+		{"12345678901234567890", true},
+		{"12345", false},
+		{"123456789012345678901", false},
+		{"1234-5678", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		errs := FieldErrors{}
+		validateNomorRekening(errs, "noRek", tt.nomorRekening)
+		if _, invalid := errs["noRek"]; invalid == tt.valid {
+			t.Errorf("validateNomorRekening(%q) valid = %v, want %v", tt.nomorRekening, !invalid, tt.valid)
+		}
+	}
+}
+
+func TestValidateTglLahir(t *testing.T) {
+	tests := []struct {
+		name     string
+		tglLahir time.Time
+		valid    bool
+	}{
+		{"masa lalu", time.Date(1995, 5, 17, 0, 0, 0, 0, time.UTC), true},
+		{"kosong", time.Time{}, false},
+		{"masa depan", time.Now().AddDate(0, 0, 1), false},
+	}
+	for _, tt := range tests {
+		errs := FieldErrors{}
+		validateTglLahir(errs, "date", tt.tglLahir)
+		if _, invalid := errs["date"]; invalid == tt.valid {
+			t.Errorf("%s: validateTglLahir valid = %v, want %v", tt.name, !invalid, tt.valid)
+		}
+	}
+}
+
+func TestValidateRequired(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"Budi", true},
+		{"", false},
+		{"   ", false},
+		{"\t\n", false},
+	}
+	for _, tt := range tests {
+		errs := FieldErrors{}
+		validateRequired(errs, "name", tt.value, "Nama wajib diisi")
+		if _, invalid := errs["name"]; invalid == tt.valid {
+			t.Errorf("validateRequired(%q) valid = %v, want %v", tt.value, !invalid, tt.valid)
+		}
+	}
+}
+
+func TestFieldErrorsKeepsFirstMessage(t *testing.T) {
+	errs := FieldErrors{}
+	errs.add("number", "pertama")
+	errs.add("number", "kedua")
+	if errs["number"] != "pertama" {
+		t.Errorf("errs[number] = %q, want %q", errs["number"], "pertama")
+	}
+}